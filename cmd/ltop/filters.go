@@ -0,0 +1,10 @@
+package main
+
+// Filters linked into the binary register themselves on import.
+import (
+	_ "github.com/almariah/ltop/pkg/filter/http"
+	_ "github.com/almariah/ltop/pkg/filter/json"
+	_ "github.com/almariah/ltop/pkg/filter/logfmt"
+	_ "github.com/almariah/ltop/pkg/filter/regex"
+	_ "github.com/almariah/ltop/pkg/filter/syslog"
+)
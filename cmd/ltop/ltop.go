@@ -16,6 +16,7 @@ import (
 	"github.com/spf13/pflag"
 	"flag"
 	"fmt"
+	"regexp"
 )
 
 func main() {
@@ -39,21 +40,74 @@ func NewlTopCommand(in io.Reader, out, err io.Writer) *cobra.Command {
 		},
 	}
 
-	cmds.Flags().StringP("log-file", "l", "/tmp/access.log", "The path to the log file")
+	addFilterFlags(cmds.Flags())
 
-	cmds.Flags().StringP("filter", "f", "", "The filter name to parse the log file")
-	cmds.MarkFlagRequired("filter")
+	cmds.Flags().Bool("event-time", false, "Bucket entries by their own timestamp instead of the time they are read")
 
-	cmds.Flags().IntP("collect-interval", "c", 5, "The interval for metrics collection in seconds")
+	cmds.AddCommand(NewFiltersCommand(out))
+	cmds.AddCommand(NewReplayCommand(out))
 
-	cmds.Flags().Int64P("evaluate-interval", "e", 10, "The interval which metrics evaluated (or interpolated if needed) in seconds")
-
-	cmds.Flags().Float64P("alert-threshold", "", 10, "The alert threshold for total number of request per second")
-	cmds.Flags().Int64P("alert-evaluate-interval", "", 120, "The alert evaluation interval in second")
-	
 	return cmds
 }
 
+// addFilterFlags adds the flags shared by the live and the replay mode.
+func addFilterFlags(flags *pflag.FlagSet) {
+
+	flags.StringP("log-file", "l", "/tmp/access.log", "The path to the log file")
+
+	flags.StringP("filter", "f", "", "The filter name to parse the log file")
+	cobra.MarkFlagRequired(flags, "filter")
+
+	flags.StringArrayP("filter-option", "o", nil, "Option passed to the filter in key=value form (see ltop filters)")
+
+	flags.IntP("collect-interval", "c", 5, "The interval for metrics collection in seconds")
+
+	flags.Int64P("evaluate-interval", "e", 10, "The interval which metrics evaluated (or interpolated if needed) in seconds")
+
+	flags.String("multiline-start", "", "Regular expression matching the first line of a multi-line entry")
+	flags.String("multiline-continue", "", "Regular expression matching the continuation lines of a multi-line entry")
+	flags.Int("multiline-max-lines", 500, "The maximum number of lines joined into one entry")
+	flags.Duration("multiline-timeout", 5*time.Second, "The time after which a pending multi-line entry is flushed")
+
+	flags.Int("max-series-per-metric", 1000, "The maximum number of label sets of a metric, further label sets are folded into an __overflow__ series (0 is unlimited)")
+	flags.Int("max-series", 10000, "The maximum number of label sets of all metrics, further label sets are folded into __overflow__ series (0 is unlimited)")
+
+	flags.String("relabel-config", "", "JSON file with relabel rules by metric name applied before series are created")
+
+	flags.String("dead-letter-file", "", "The file receiving the lines which could not be parsed")
+	flags.Int64("dead-letter-max-size", 10<<20, "The size in bytes after which the dead-letter file is rotated")
+	flags.Int("dead-letter-max-files", 3, "The number of rotated dead-letter files kept")
+
+	flags.Duration("allowed-lateness", 30*time.Second, "The lateness accepted for entries in event-time mode")
+
+	flags.Float64P("alert-threshold", "", 10, "The alert threshold for total number of request per second")
+	flags.Int64P("alert-evaluate-interval", "", 120, "The alert evaluation interval in second")
+}
+
+func NewFiltersCommand(out io.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "filters",
+		Short: "List the available filters and their options",
+		Run: func(cmd *cobra.Command, args []string) {
+			printFilters(out)
+		},
+	}
+}
+
+func printFilters(out io.Writer) {
+	for _, r := range filter.Registered() {
+		fmt.Fprintf(out, "%s\n    %s\n", r.Name, r.Description)
+		for _, o := range r.Options {
+			fmt.Fprintf(out, "    -o %s=<value>\t%s", o.Name, o.Usage)
+			if o.Default != "" {
+				fmt.Fprintf(out, " (default %q)", o.Default)
+			}
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintln(out)
+	}
+}
+
 func runApp(cmd *cobra.Command, out io.Writer) {
 
 	logFile, err := cmd.Flags().GetString("log-file")
@@ -61,40 +115,133 @@ func runApp(cmd *cobra.Command, out io.Writer) {
 		glog.Fatal(err)
 	}
 
-	filterName, err := cmd.Flags().GetString("filter")
+	evalInterval, err := cmd.Flags().GetInt64("evaluate-interval")
 	if err != nil {
 		glog.Fatal(err)
 	}
 
-	evalInterval, err := cmd.Flags().GetInt64("evaluate-interval")
+	eventTime, err := cmd.Flags().GetBool("event-time")
 	if err != nil {
 		glog.Fatal(err)
 	}
 
-	f, err := selectFilter(filterName)
+	f, multiline, err := setupFilter(cmd, eventTime)
 	if err != nil {
 		glog.Warning(err)
 		return
 	}
-			
-	tailer, err := log.NewTailer(f, logFile)
+
+	log.RegisterMetrics()
+
+	tailer, err := log.NewTailer(f, logFile, multiline)
 	if err != nil {
 		panic(err)
 	}
 
+	p := printer.NewPrinter(out)
+
+	go startRenderLoop(f, p, evalInterval)
+
+	metrics.StartAlertManager()
+	go metrics.Gather()
+
+	go startAlertRenderLoop(p)
+
+	done := make(chan os.Signal, 1)
+	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	<-done
+	tailer.Stop()
+	if deadLetter != nil {
+		deadLetter.Close()
+	}
+}
+
+// setupFilter creates the filter given by the flags and registers its
+// metrics and monitors.
+func setupFilter(cmd *cobra.Command, eventTime bool) (filter.Filter, *log.MultilineConfig, error) {
+
+	maxSeriesPerMetric, err := cmd.Flags().GetInt("max-series-per-metric")
+	if err != nil {
+		return nil, nil, err
+	}
+	maxSeries, err := cmd.Flags().GetInt("max-series")
+	if err != nil {
+		return nil, nil, err
+	}
+	metrics.SetSeriesLimits(maxSeriesPerMetric, maxSeries)
+
+	relabelConfig, err := cmd.Flags().GetString("relabel-config")
+	if err != nil {
+		return nil, nil, err
+	}
+	if relabelConfig != "" {
+		configs, err := metrics.LoadRelabelConfigs(relabelConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := metrics.SetRelabelConfigs(configs); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	filterName, err := cmd.Flags().GetString("filter")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filterOptions, err := cmd.Flags().GetStringArray("filter-option")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts, err := filter.ParseOptions(filterOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := filter.New(filterName, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dl, err := deadLetterFile(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	// a nil file must not become a non-nil writer
+	var w io.Writer
+	if dl != nil {
+		deadLetter = dl
+		w = dl
+	}
+	f = filter.WithErrorAccounting(filterName, f, w)
+
+	multiline, err := multilineConfig(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	ci, err := cmd.Flags().GetInt("collect-interval")
 	if err != nil {
-		glog.Fatal(err)
+		return nil, nil, err
 	}
 	metrics.SetCollectInterval(ci)
 
+	if eventTime {
+		lateness, err := cmd.Flags().GetDuration("allowed-lateness")
+		if err != nil {
+			return nil, nil, err
+		}
+		metrics.SetEventTime(lateness)
+	}
+
 	alertThreshold, err := cmd.Flags().GetFloat64("alert-threshold")
 	if err != nil {
-		glog.Fatal(err)
+		return nil, nil, err
 	}
 	alertEvaluateInterval, err := cmd.Flags().GetInt64("alert-evaluate-interval")
 	if err != nil {
-		glog.Fatal(err)
+		return nil, nil, err
 	}
 	httpfilter.SetAlertThreshold(alertThreshold)
 	httpfilter.SetAlertEvaluateInterval(alertEvaluateInterval)
@@ -102,34 +249,50 @@ func runApp(cmd *cobra.Command, out io.Writer) {
 	f.RegisterMetrics()
 	f.RegisterMonitors()
 
-	p := printer.NewPrinter(out)
+	return f, multiline, nil
+}
 
-	go startRenderLoop(f, p, evalInterval)
+// receives the lines which could not be parsed, nil if not configured
+var deadLetter *log.DeadLetterFile
 
-	metrics.StartAlertManager()
-	go metrics.Gather()
+// deadLetterFile opens the dead-letter file given by the flags, nil if lines
+// which could not be parsed are not kept.
+func deadLetterFile(cmd *cobra.Command) (*log.DeadLetterFile, error) {
 
-	go startAlertRenderLoop(p)
+	path, err := cmd.Flags().GetString("dead-letter-file")
+	if err != nil || path == "" {
+		return nil, err
+	}
+	maxSize, err := cmd.Flags().GetInt64("dead-letter-max-size")
+	if err != nil {
+		return nil, err
+	}
+	maxFiles, err := cmd.Flags().GetInt("dead-letter-max-files")
+	if err != nil {
+		return nil, err
+	}
 
-	done := make(chan os.Signal, 1)
-	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
-	<-done
-	tailer.Stop()
+	return log.NewDeadLetterFile(path, maxSize, maxFiles)
 }
 
 func startRenderLoop(f filter.Filter, p *printer.Printer, evalInterval int64) {
-	s := f.Summary(evalInterval)
-	p.Render(s)
+	p.Render(summary(f, evalInterval))
 	for {
 		select {
 
 		case <-time.After(10 * time.Second):
-			s := f.Summary(evalInterval)
-			p.Render(s)
+			p.Render(summary(f, evalInterval))
 		}
 	}		
 }
 
+// summary returns the summary of the filter with the warnings of the metrics.
+func summary(f filter.Filter, evalInterval int64) printer.Summary {
+	s := f.Summary(evalInterval)
+	s.Warnings = append(s.Warnings, metrics.SeriesWarnings()...)
+	return s
+}
+
 
 func startAlertRenderLoop(p *printer.Printer) {
 	alerts := metrics.Alerts()
@@ -143,11 +306,45 @@ func startAlertRenderLoop(p *printer.Printer) {
 	}	
 }
 
-func selectFilter(name string) (filter.Filter, error) {
-	switch name {
-	case "http-access-log":
-		return httpfilter.NewHTTPAccessLogFilter(), nil
-	default:
-		return nil, fmt.Errorf("invalid filter name; %s", name)
+// multilineConfig returns the multi-line configuration given by the flags,
+// nil if lines are not joined.
+func multilineConfig(cmd *cobra.Command) (*log.MultilineConfig, error) {
+
+	start, err := cmd.Flags().GetString("multiline-start")
+	if err != nil {
+		return nil, err
+	}
+	cont, err := cmd.Flags().GetString("multiline-continue")
+	if err != nil {
+		return nil, err
 	}
-}
\ No newline at end of file
+	if start == "" && cont == "" {
+		return nil, nil
+	}
+
+	maxLines, err := cmd.Flags().GetInt("multiline-max-lines")
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := cmd.Flags().GetDuration("multiline-timeout")
+	if err != nil {
+		return nil, err
+	}
+
+	config := &log.MultilineConfig{
+		MaxLines: maxLines,
+		Timeout:  timeout,
+	}
+	if start != "" {
+		if config.Start, err = regexp.Compile(start); err != nil {
+			return nil, fmt.Errorf("invalid multiline-start: %s", err)
+		}
+	}
+	if cont != "" {
+		if config.Continue, err = regexp.Compile(cont); err != nil {
+			return nil, fmt.Errorf("invalid multiline-continue: %s", err)
+		}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/almariah/ltop/pkg/printer"
+	"github.com/almariah/ltop/pkg/replay"
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+)
+
+func NewReplayCommand(out io.Writer) *cobra.Command {
+
+	cmd := &cobra.Command{
+		Use:   "replay",
+		Short: "Replay a historical log file on a simulated clock and report the alerts",
+		Long: "Reads the log file to the end as fast as possible. The metrics are bucketed by the " +
+			"timestamps of the entries and the alerts are evaluated on a clock driven by them, " +
+			"so a day of logs replays in seconds.",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runReplay(cmd, out); err != nil {
+				glog.Warning(err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	addFilterFlags(cmd.Flags())
+
+	return cmd
+}
+
+func runReplay(cmd *cobra.Command, out io.Writer) error {
+
+	logFile, err := cmd.Flags().GetString("log-file")
+	if err != nil {
+		return err
+	}
+
+	evalInterval, err := cmd.Flags().GetInt64("evaluate-interval")
+	if err != nil {
+		return err
+	}
+
+	alertEvaluateInterval, err := cmd.Flags().GetInt64("alert-evaluate-interval")
+	if err != nil {
+		return err
+	}
+
+	f, multiline, err := setupFilter(cmd, true)
+	if err != nil {
+		return err
+	}
+	if deadLetter != nil {
+		defer deadLetter.Close()
+	}
+
+	file, err := os.Open(logFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// the metrics of the tailer, e.g. ltop_tailer_lag_bytes, are not
+	// registered as the file is read to its end without a tailer, there is
+	// no lag to measure
+	report, err := replay.Run(f, file, replay.Config{
+		Multiline:    multiline,
+		EvalInterval: evalInterval,
+		Step:         alertEvaluateInterval,
+	})
+	if err != nil {
+		return err
+	}
+
+	p := printer.NewPrinter(out)
+
+	for i := range report.Alerts {
+		p.Alert(&report.Alerts[i])
+	}
+
+	p.Render(report.Summary)
+	p.Render(printer.Summary{
+		Tables: []printer.Table{reportTable(report)},
+	})
+
+	return nil
+}
+
+func reportTable(report *replay.Report) printer.Table {
+	return printer.Table{
+		Title:  "replay",
+		Header: []string{"start", "end", "entries", "errors", "dropped late", "alerts"},
+		Data: [][]string{{
+			report.Start.UTC().String(),
+			report.End.UTC().String(),
+			fmt.Sprint(report.Entries),
+			fmt.Sprint(report.Errors),
+			fmt.Sprint(report.Dropped),
+			fmt.Sprint(len(report.Alerts)),
+		}},
+	}
+}
@@ -3,8 +3,10 @@ module github.com/almariah/ltop
 go 1.12
 
 require (
+	github.com/beorn7/perks v1.0.0
 	github.com/cespare/xxhash v1.1.0
 	github.com/cespare/xxhash/v2 v2.1.1
+	github.com/go-logfmt/logfmt v0.4.0
 	github.com/golang/glog v0.0.0-20160126235308-23def4e6c14b
 	github.com/guptarohit/asciigraph v0.4.1
 	github.com/hpcloud/tail v1.0.0
@@ -0,0 +1,189 @@
+package filter
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/almariah/ltop/pkg/metrics"
+	"github.com/almariah/ltop/pkg/printer"
+	"github.com/golang/glog"
+)
+
+// reasons of parse errors
+const (
+	// the line does not match the format of the filter
+	ReasonFormat = "format"
+	// the timestamp of the line could not be parsed
+	ReasonTime = "time"
+	// a numeric field could not be parsed
+	ReasonValue = "value"
+	// a required field is missing
+	ReasonMissingField = "missing_field"
+	// errors returned without a reason
+	ReasonUnknown = "unknown"
+)
+
+// ParseError is returned by filters for lines which could not be parsed.
+type ParseError struct {
+	Reason string
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return e.Err.Error()
+}
+
+// ParseErrorf returns a ParseError of the given reason.
+func ParseErrorf(reason string, format string, args ...interface{}) error {
+	return &ParseError{Reason: reason, Err: fmt.Errorf(format, args...)}
+}
+
+// ErrorReason returns the reason of a ParseError, ReasonUnknown for other
+// errors.
+func ErrorReason(err error) string {
+	if pe, ok := err.(*ParseError); ok {
+		return pe.Reason
+	}
+	return ReasonUnknown
+}
+
+// metrics
+var (
+	entriesCounter = metrics.NewCounterVec(
+		"ltop_entries_total",
+		"Counter of log entries handled broken out by filter.",
+		[]string{"filter"},
+	)
+
+	parseErrorsCounter = metrics.NewCounterVec(
+		"ltop_parse_errors_total",
+		"Counter of log entries which could not be parsed broken out by filter and reason.",
+		[]string{"filter", "reason"},
+	)
+)
+
+type accountingFilter struct {
+	Filter
+	name string
+
+	entries metrics.Counter
+
+	// receives the raw lines which could not be parsed, may be nil
+	deadLetter io.Writer
+	mtx        sync.Mutex
+	// only the first write error of the dead letters is logged
+	writeFailed bool
+}
+
+// WithErrorAccounting wraps the filter with the given name to count the
+// entries and the parse errors by reason. The lines which could not be
+// parsed are written to deadLetter unless it is nil.
+func WithErrorAccounting(name string, f Filter, deadLetter io.Writer) Filter {
+	return &accountingFilter{
+		Filter:     f,
+		name:       name,
+		entries:    entriesCounter.WithLabelValues(name),
+		deadLetter: deadLetter,
+	}
+}
+
+func (f *accountingFilter) RegisterMetrics() {
+	metrics.Register(entriesCounter, parseErrorsCounter)
+	f.Filter.RegisterMetrics()
+}
+
+func (f *accountingFilter) HandleEntry(time time.Time, entry string) error {
+
+	f.entries.Inc()
+
+	err := f.Filter.HandleEntry(time, entry)
+	if err == nil {
+		return nil
+	}
+
+	parseErrorsCounter.WithLabelValues(f.name, ErrorReason(err)).Inc()
+
+	if f.deadLetter != nil {
+		f.mtx.Lock()
+		if _, werr := f.deadLetter.Write([]byte(entry + "\n")); werr != nil && !f.writeFailed {
+			glog.Errorf("could not write dead letter of filter %s: %s", f.name, werr)
+			f.writeFailed = true
+		}
+		f.mtx.Unlock()
+	}
+
+	return err
+}
+
+func (f *accountingFilter) Summary(evalInterval int64) printer.Summary {
+
+	summary := f.Filter.Summary(evalInterval)
+
+	entries := f.entries.(metrics.Metric).Value()
+	if entries == 0 {
+		return summary
+	}
+
+	tb := printer.Table{
+		Title:  "parsing",
+		Header: []string{"entries", "parse errors", "success ratio"},
+	}
+
+	var errors float64
+	byReason := map[string]float64{}
+	ch := make(chan metrics.Metric, 100)
+	go func() {
+		parseErrorsCounter.Collect(ch)
+		close(ch)
+	}()
+	for m := range ch {
+		var name, reason string
+		for _, l := range m.Labels() {
+			switch l.Name {
+			case "filter":
+				name = l.Value
+			case "reason":
+				reason = l.Value
+			}
+		}
+		if name != f.name {
+			continue
+		}
+		errors += m.Value()
+		byReason[reason] += m.Value()
+	}
+
+	ratio := 100 * (entries - errors) / entries
+	tb.Data = append(tb.Data, []string{
+		fmt.Sprintf("%.0f", entries),
+		formatReasons(errors, byReason),
+		fmt.Sprintf("%.2f%%", ratio),
+	})
+
+	summary.Tables = append(summary.Tables, tb)
+	return summary
+}
+
+// formatReasons returns the number of errors followed by the numbers by
+// reason, e.g. "51 (format=40, time=11)".
+func formatReasons(errors float64, byReason map[string]float64) string {
+	if len(byReason) == 0 {
+		return fmt.Sprintf("%.0f", errors)
+	}
+
+	reasons := make([]string, 0, len(byReason))
+	for reason := range byReason {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+
+	parts := make([]string, len(reasons))
+	for i, reason := range reasons {
+		parts[i] = fmt.Sprintf("%s=%.0f", reason, byReason[reason])
+	}
+	return fmt.Sprintf("%.0f (%s)", errors, strings.Join(parts, ", "))
+}
@@ -0,0 +1,77 @@
+package filter
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/almariah/ltop/pkg/printer"
+)
+
+type stubFilter struct{}
+
+func (stubFilter) HandleEntry(t time.Time, entry string) error {
+	switch entry {
+	case "bad time":
+		return ParseErrorf(ReasonTime, "could not parse time of line: '%s'", entry)
+	case "garbage":
+		return ParseErrorf(ReasonFormat, "could not parse line: '%s'", entry)
+	}
+	return nil
+}
+
+func (stubFilter) Summary(int64) printer.Summary { return printer.Summary{} }
+func (stubFilter) RegisterMetrics()              {}
+func (stubFilter) RegisterMonitors()             {}
+
+func TestWithErrorAccounting(t *testing.T) {
+	var deadLetter bytes.Buffer
+	f := WithErrorAccounting("stub", stubFilter{}, &deadLetter)
+
+	for _, line := range []string{"ok", "bad time", "garbage", "ok"} {
+		err := f.HandleEntry(time.Now(), line)
+		if (err != nil) != (line != "ok") {
+			t.Fatalf("unexpected error %v for %q", err, line)
+		}
+	}
+
+	if deadLetter.String() != "bad time\ngarbage\n" {
+		t.Fatalf("unexpected dead letters %q", deadLetter.String())
+	}
+
+	s := f.Summary(10)
+	if len(s.Tables) != 1 {
+		t.Fatalf("expected the parsing table, got %v", s.Tables)
+	}
+	row := strings.Join(s.Tables[0].Data[0], " | ")
+	if row != "4 | 2 (format=1, time=1) | 50.00%" {
+		t.Fatalf("unexpected parsing row %q", row)
+	}
+}
+
+type failingWriter struct {
+	writes int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return 0, errors.New("disk full")
+}
+
+func TestWithErrorAccountingWriteError(t *testing.T) {
+	w := &failingWriter{}
+	f := WithErrorAccounting("failing", stubFilter{}, w)
+
+	for _, line := range []string{"garbage", "garbage"} {
+		if err := f.HandleEntry(time.Now(), line); ErrorReason(err) != ReasonFormat {
+			t.Fatalf("expected the parse error, got %v", err)
+		}
+	}
+
+	// the write error is logged once, the following lines are still tried
+	if w.writes != 2 || !f.(*accountingFilter).writeFailed {
+		t.Fatalf("unexpected writes %d", w.writes)
+	}
+}
@@ -0,0 +1,110 @@
+package filter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Names of the time layouts accepted by ParseTime in addition to Go layouts.
+var timeLayouts = map[string]string{
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	"rfc1123":     time.RFC1123,
+	"rfc1123z":    time.RFC1123Z,
+	"rfc822":      time.RFC822,
+	"rfc822z":     time.RFC822Z,
+	"ansic":       time.ANSIC,
+	"unixdate":    time.UnixDate,
+	"stamp":       time.Stamp,
+	"clf":         "02/Jan/2006:15:04:05 -0700",
+}
+
+// ParseTime parses v with the given layout. The layout is either a Go time
+// layout, one of rfc3339, rfc3339nano, rfc1123, rfc1123z, rfc822, rfc822z,
+// ansic, unixdate, stamp and clf, or one of unix, unix_ms, unix_us and
+// unix_ns for (fractional) epoch timestamps.
+func ParseTime(layout string, v string) (time.Time, error) {
+
+	switch layout {
+	case "unix", "unix_ms", "unix_us", "unix_ns":
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid %s timestamp %q", layout, v)
+		}
+		switch layout {
+		case "unix":
+			f *= float64(time.Second)
+		case "unix_ms":
+			f *= float64(time.Millisecond)
+		case "unix_us":
+			f *= float64(time.Microsecond)
+		}
+		return time.Unix(0, int64(f)), nil
+	}
+
+	if l, ok := timeLayouts[strings.ToLower(layout)]; ok {
+		layout = l
+	}
+
+	return time.Parse(layout, v)
+}
+
+// ParseValue parses a numeric field. Durations like 12ms or 1.5s are
+// returned in seconds.
+func ParseValue(v string) (float64, error) {
+
+	if f, err := strconv.ParseFloat(v, 64); err == nil {
+		return f, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid numeric value %q", v)
+	}
+
+	return d.Seconds(), nil
+}
+
+// CheckCounterValue returns an error if the value cannot be added to a
+// counter, which cannot decrease.
+func CheckCounterValue(v float64) error {
+	if !(v >= 0) || math.IsInf(v, 1) {
+		return fmt.Errorf("invalid counter increment %v", v)
+	}
+	return nil
+}
+
+// MetricName turns a field name into a valid metric or label name by
+// replacing every character other than letters, digits and '_'.
+func MetricName(field string) string {
+	b := []byte(field)
+	for i, c := range b {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' && i > 0) {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// Mapping maps a field of a structured entry to a metric or label name.
+type Mapping struct {
+	Name  string
+	Field string
+}
+
+// ParseMappings parses a list of name=field mappings. A mapping given as
+// a field only is named after the field using MetricName.
+func ParseMappings(list []string) []Mapping {
+	var result []Mapping
+	for _, m := range list {
+		if i := strings.Index(m, "="); i > 0 {
+			result = append(result, Mapping{Name: m[:i], Field: m[i+1:]})
+			continue
+		}
+		result = append(result, Mapping{Name: MetricName(m), Field: m})
+	}
+	return result
+}
@@ -4,16 +4,23 @@ import (
 	"time"
 	"github.com/almariah/ltop/pkg/metrics"
 	"github.com/almariah/ltop/pkg/printer"
+	"github.com/almariah/ltop/pkg/filter"
+	"github.com/almariah/ltop/pkg/geoip"
 	"fmt"
 	"regexp"
 	"bytes"
 	"strconv"
-	"strings"
 	"github.com/golang/glog"
 )
 
 const (
 	evalIntervalNumber = 60
+
+	// heavy hitters and distinct clients are tracked over the last topKWindow
+	topKWindow   = 10 * time.Minute
+	topKSlots    = 10
+	topKCapacity = 100
+	topN         = 10
 )
 
 var (
@@ -29,15 +36,198 @@ var (
 		"Counter of requests broken out for each verb, section, and HTTP response code.",
 		[]string{"method", "section", "status"},
 	)
+
+	clientRequestCounter = metrics.NewCounterVec(
+		"client_requests_total",
+		"Counter of requests broken out for each client class, browser or tool family, and device.",
+		[]string{"class", "family", "device"},
+	)
+
+	networkRequestCounter = metrics.NewCounterVec(
+		"network_requests_total",
+		"Counter of requests broken out for each client network.",
+		[]string{"client_net"},
+	)
+
+	referralCounter = metrics.NewCounterVec(
+		"referral_requests_total",
+		"Counter of requests broken out for direct, internal and external referrals.",
+		[]string{"referral"},
+	)
+
+	bytesSentCounter = metrics.NewCounterVec(
+		"bytes_sent_total",
+		"Counter of bytes sent broken out for each verb, section, and HTTP response code.",
+		[]string{"method", "section", "status"},
+	)
+
+	responseSizeHistogram = metrics.NewHistogramVec(
+		"response_size_bytes",
+		"Histogram of response sizes broken out for each verb.",
+		metrics.ExponentialBuckets(100, 4, 8),
+		[]string{"method"},
+	)
+
+	topClients    = metrics.NewTopK(topKCapacity, topKWindow, topKSlots)
+	topURIs       = metrics.NewTopK(topKCapacity, topKWindow, topKSlots)
+	topUserAgents = metrics.NewTopK(topKCapacity, topKWindow, topKSlots)
+
+	// quantiles of the request durations
+	durationObjectives = map[float64]float64{0.5: 0.05, 0.95: 0.01, 0.99: 0.001}
+
+	requestDuration = metrics.NewQuantileSummary(metrics.QuantileSummaryOpts{
+		Name:       "request_duration_seconds",
+		Help:       "Quantiles of the time taken to serve requests of the last 10 minutes.",
+		Objectives: durationObjectives,
+	})
+
+	sectionRequestDuration = metrics.NewQuantileSummaryVec(metrics.QuantileSummaryOpts{
+		Name:       "section_request_duration_seconds",
+		Help:       "Quantiles of the time taken to serve requests of the last 10 minutes broken out for each section.",
+		Objectives: durationObjectives,
+	}, []string{"section"})
+
+	topReferrers = metrics.NewTopK(topKCapacity, topKWindow, topKSlots)
+	topCountries = metrics.NewTopK(topKCapacity, topKWindow, topKSlots)
+	topASes      = metrics.NewTopK(topKCapacity, topKWindow, topKSlots)
+
+	distinctClients = metrics.NewCardinalityVec(
+		"distinct_clients",
+		"Estimated number of distinct remote hosts of the last 10 minutes broken out for each section.",
+		topKWindow,
+		topKSlots,
+		[]string{"section"},
+	)
+
+	lastResponseSize = metrics.NewGaugeVec(
+		"last_response_bytes",
+		"Size of the last response broken out for each section.",
+		[]string{"section"},
+	)
 	
 )
 
+func init() {
+	filter.Register(filter.Registration{
+		Name:        "http-access-log",
+		Description: "HTTP access log in combined or common log format",
+		Options: append([]filter.Option{
+			{
+				Name:  "log-format",
+				Usage: "Apache LogFormat string (or common, combined) used instead of the combined log format",
+			},
+		}, sharedOptions()...),
+		New: func(opts filter.Options) (filter.Filter, error) {
+			f := NewHTTPAccessLogFilter()
+			if format := opts.String("log-format"); format != "" {
+				var err error
+				if f, err = NewHTTPAccessLogFilterWithFormat(format); err != nil {
+					return nil, err
+				}
+			}
+			if err := f.configure(opts); err != nil {
+				return nil, err
+			}
+			return f, nil
+		},
+	})
+}
+
 type HTTPAccessLogFilter struct {
 	re *regexp.Regexp
+	// format is used instead of re when a custom log format is given
+	format *logFormat
+	// derive the section of requests, nil for the first path segment
+	sections *SectionRules
+	// classifies user agents, nil for the built-in rules
+	userAgents *UserAgentClassifier
+	// add the client class and device labels to request_total
+	clientLabels bool
+	// derive the client network of requests, nil for private or public
+	networks *NetworkRules
+	// classify referrers, nil for defaultReferrerRules
+	referrers *ReferrerRules
+	// resolves the country and AS of clients, nil without GeoIP databases
+	geoip *geoip.Resolver
+	// add the country and asn labels to request_total
+	geoLabels bool
+	// request_total with the optional labels, nil for requestCounter
+	requests *metrics.CounterVec
+	// numeric variables of an nginx log format observed into summaries
+	values []valueSummary
 	quit chan struct{}
 	done chan struct{}
 }
 
+// sharedOptions returns the options of the HTTP filters applied by
+// configure.
+func sharedOptions() []filter.Option {
+	var opts []filter.Option
+	opts = append(opts, sectionOptions...)
+	opts = append(opts, clientOptions...)
+	opts = append(opts, networkOptions...)
+	opts = append(opts, referrerOptions...)
+	return append(opts, geoipOptions...)
+}
+
+// configure applies the section, client, network, referrer and GeoIP
+// options shared by the HTTP filters.
+func (f *HTTPAccessLogFilter) configure(opts filter.Options) error {
+
+	sections, err := NewSectionRules(opts)
+	if err != nil {
+		return err
+	}
+	userAgents, err := newUserAgentClassifierFromOptions(opts)
+	if err != nil {
+		return err
+	}
+	clientLabels, err := opts.Bool("client-labels")
+	if err != nil {
+		return err
+	}
+	networks, err := newNetworkRulesFromOptions(opts)
+	if err != nil {
+		return err
+	}
+	resolver, err := newGeoIPResolverFromOptions(opts)
+	if err != nil {
+		return err
+	}
+	geoLabels, err := opts.Bool("geoip-labels")
+	if err != nil {
+		return err
+	}
+	if geoLabels && resolver == nil {
+		return fmt.Errorf("geoip-labels requires geoip-database")
+	}
+
+	f.sections = sections
+	f.userAgents = userAgents
+	f.clientLabels = clientLabels
+	f.networks = networks
+	f.referrers = newReferrerRulesFromOptions(opts)
+	f.geoip = resolver
+	f.geoLabels = geoLabels
+
+	if clientLabels || geoLabels {
+		labels := []string{"method", "section", "status"}
+		if clientLabels {
+			labels = append(labels, "client_class", "device")
+		}
+		if geoLabels {
+			labels = append(labels, "country", "asn")
+		}
+		f.requests = metrics.NewCounterVec(
+			"request_total",
+			"Counter of requests broken out for each verb, section, HTTP response code and client.",
+			labels,
+		)
+	}
+
+	return nil
+}
+
 type HTTPAccessLogEntry struct {
 	RemoteHost    string
 	RemoteLogname string
@@ -45,7 +235,7 @@ type HTTPAccessLogEntry struct {
 	Time          time.Time
 	Method        string
 	URI           string
-	// uri before the second '/'
+	// first path segment of the uri, unless other section rules are used
 	Section       string
 	Protocol      string
 	Status        int
@@ -53,6 +243,23 @@ type HTTPAccessLogEntry struct {
 	Referer       string
 	UserAgent     string
 	URL           string
+	// time taken to serve the request, set if HasDuration
+	Duration      time.Duration
+	// the log format has a duration, e.g. %D or $request_time
+	HasDuration   bool
+	// directives of a custom log format without a dedicated field
+	Fields        map[string]string
+	// numeric fields of a custom log format, e.g. $request_time
+	Values        map[string]float64
+
+	// fraction of the second logged by %{msec_frac}t or %{usec_frac}t
+	timeFrac time.Duration
+}
+
+// Value returns the numeric field with the given name, e.g. "$request_time".
+func (e *HTTPAccessLogEntry) Value(name string) (float64, bool) {
+	v, ok := e.Values[name]
+	return v, ok
 }
 
 func (e *HTTPAccessLogEntry) parse(entry string, re *regexp.Regexp) error {
@@ -60,24 +267,28 @@ func (e *HTTPAccessLogEntry) parse(entry string, re *regexp.Regexp) error {
 	matches := re.FindStringSubmatch(entry)
 
 	if len(matches) != 12 {
-		return fmt.Errorf("could not parse line: '%s'", matches)
+		return filter.ParseErrorf(filter.ReasonFormat, "could not parse line: '%s'", entry)
 	}
 
 
 	time, err := time.Parse("02/Jan/2006:15:04:05 -0700", matches[4])
 	if err != nil {
-		return fmt.Errorf("could not parse time of line: '%s'", matches)
+		return filter.ParseErrorf(filter.ReasonTime, "could not parse time of line: '%s'", entry)
 	}
 
 	status, err := strconv.Atoi(matches[8])
 	if err != nil {
-		return fmt.Errorf("could not parse status code of line: '%s'", matches)
+		return filter.ParseErrorf(filter.ReasonValue, "could not parse status code of line: '%s'", entry)
 	}
 
-	bytesSent, err := strconv.Atoi(matches[9])
-	if err != nil {
-		return fmt.Errorf("could not parse bytes sent of line: '%s'", matches)
-	}	
+	// '-' is logged if no content was sent
+	bytesSent := 0
+	if matches[9] != "-" {
+		bytesSent, err = strconv.Atoi(matches[9])
+		if err != nil {
+			return filter.ParseErrorf(filter.ReasonValue, "could not parse bytes sent of line: '%s'", entry)
+		}
+	}
 
 	e.RemoteHost = matches[1]
 	e.RemoteLogname = matches[2]
@@ -91,13 +302,15 @@ func (e *HTTPAccessLogEntry) parse(entry string, re *regexp.Regexp) error {
 	e.Referer = matches[10]
 	e.UserAgent = matches[11]
 
-	uriSections := strings.Split(e.URI, "/")
-	parms := strings.Split(uriSections[1], "?")
-	e.Section = "/" + parms[0]
+	e.setSection()
 
 	return nil
 }
 
+func (e *HTTPAccessLogEntry) setSection() {
+	e.Section = defaultSectionRules.Section(e.URI)
+}
+
 func NewHTTPAccessLogFilter() *HTTPAccessLogFilter {
 
 	var buffer bytes.Buffer
@@ -124,8 +337,44 @@ func NewHTTPAccessLogFilter() *HTTPAccessLogFilter {
 	}
 }
 
+// NewHTTPAccessLogFilterWithFormat returns a filter parsing lines with the
+// given Apache LogFormat string.
+func NewHTTPAccessLogFilterWithFormat(format string) (*HTTPAccessLogFilter, error) {
+
+	lf, err := CompileApacheLogFormat(format)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HTTPAccessLogFilter{
+		format: lf,
+	}, nil
+}
+
 func (f HTTPAccessLogFilter) RegisterMetrics() {
-	metrics.Register(requestCounter)
+	metrics.Register(f.requestVec())
+	metrics.Register(clientRequestCounter)
+	metrics.Register(networkRequestCounter)
+	metrics.Register(referralCounter)
+	if f.format != nil && f.format.hasDuration {
+		metrics.Register(requestDuration)
+		metrics.Register(sectionRequestDuration)
+	}
+	metrics.Register(bytesSentCounter)
+	metrics.Register(responseSizeHistogram)
+	metrics.Register(distinctClients)
+	metrics.Register(lastResponseSize)
+	for _, v := range f.values {
+		metrics.Register(v.vec)
+	}
+}
+
+// requestVec returns request_total with the labels of the filter.
+func (f HTTPAccessLogFilter) requestVec() *metrics.CounterVec {
+	if f.requests != nil {
+		return f.requests
+	}
+	return requestCounter
 }
 
 func (f HTTPAccessLogFilter) RegisterMonitors() {
@@ -139,6 +388,14 @@ func (f HTTPAccessLogFilter) RegisterMonitors() {
 			alertThreshold,  // threshold
 			func() float64 {
 				l1 := []metrics.Label{}
+				if metrics.EventTime() {
+					// the buckets of the whole interval, e.g. when replaying
+					mt2 := metrics.QueryLast("request_total", l1, alertEvaluateInterval, alertEvaluateInterval)
+					if len(mt2) == 0 {
+						return 0
+					}
+					return metrics.AvgRate(metrics.Sum(mt2))
+				}
 				mt2 := metrics.QueryLast("request_total", l1, 2, 10)
 				for _, s := range mt2 {
 					return metrics.Avg(metrics.Rate(s))
@@ -155,12 +412,87 @@ func (f HTTPAccessLogFilter) RegisterMonitors() {
 func (f HTTPAccessLogFilter) HandleEntry(time time.Time, entry string) error {
 
 	e := HTTPAccessLogEntry{}
-	err := e.parse(entry, f.re)
+
+	var err error
+	if f.format != nil {
+		err = f.format.parse(entry, &e)
+	} else {
+		err = e.parse(entry, f.re)
+	}
 	if err != nil {
 		return err
 	}
 
-	requestCounter.WithLabelValues(e.Method, e.Section, string(e.Status)).Inc()
+	if f.sections != nil {
+		e.Section = f.sections.Section(e.URI)
+	}
+
+	// log formats without a time directive
+	if e.Time.IsZero() {
+		e.Time = time
+	}
+
+	userAgents := f.userAgents
+	if userAgents == nil {
+		userAgents = defaultUserAgentClassifier
+	}
+	client := userAgents.Classify(e.UserAgent)
+
+	status := strconv.Itoa(e.Status)
+	lvs := []string{e.Method, e.Section, status}
+	if f.clientLabels {
+		lvs = append(lvs, client.Class, client.Device)
+	}
+	if f.geoip != nil {
+		location := f.geoip.Lookup(e.RemoteHost)
+		if f.geoLabels {
+			asn := geoip.Unknown
+			if location.ASN != 0 {
+				asn = fmt.Sprintf("AS%d", location.ASN)
+			}
+			lvs = append(lvs, location.Country, asn)
+		}
+		topCountries.AddAt(e.Time, location.Country, 1)
+		topASes.AddAt(e.Time, location.AS(), 1)
+	}
+	f.requestVec().WithLabelValues(lvs...).IncAt(e.Time)
+	clientRequestCounter.WithLabelValues(client.Class, client.Family, client.Device).IncAt(e.Time)
+
+	networks := f.networks
+	if networks == nil {
+		networks = defaultNetworkRules
+	}
+	networkRequestCounter.WithLabelValues(networks.Network(e.RemoteHost)).IncAt(e.Time)
+
+	referrers := f.referrers
+	if referrers == nil {
+		referrers = defaultReferrerRules
+	}
+	referral, domain := referrers.Referral(&e)
+	referralCounter.WithLabelValues(referral).IncAt(e.Time)
+	if referral == ReferralExternal {
+		topReferrers.AddAt(e.Time, domain, 1)
+	}
+	bytesSentCounter.WithLabelValues(e.Method, e.Section, status).AddAt(e.Time, float64(e.BytesSent))
+	responseSizeHistogram.WithLabelValues(e.Method).ObserveAt(e.Time, float64(e.BytesSent))
+	lastResponseSize.WithLabelValues(e.Section).Set(float64(e.BytesSent))
+
+	if e.HasDuration {
+		requestDuration.ObserveAt(e.Time, e.Duration.Seconds())
+		sectionRequestDuration.WithLabelValues(e.Section).ObserveAt(e.Time, e.Duration.Seconds())
+	}
+
+	for _, v := range f.values {
+		v.observe(&e)
+	}
+
+	distinctClients.WithLabelValues(e.Section).AddAt(e.Time, e.RemoteHost)
+
+	topClients.AddAt(e.Time, e.RemoteHost, 1)
+	topURIs.AddAt(e.Time, e.URI, 1)
+	if e.UserAgent != "" && e.UserAgent != "-" {
+		topUserAgents.AddAt(e.Time, e.UserAgent, 1)
+	}
 
 	return nil
 }
@@ -189,11 +521,16 @@ func (f *HTTPAccessLogFilter) Summary(evalInterval int64) printer.Summary {
 	totalRate := metrics.Rate(sum)
 	
 	graph := printer.Graph{
-		Title: fmt.Sprintf("%s: request_total%s for last %d seconds over %d seconds interval", time.Now(), totalRate.Metric, last, evalInterval),
+		Title: fmt.Sprintf("%s: request_total%s for last %d seconds over %d seconds interval", metrics.Now(), totalRate.Metric, last, evalInterval),
 		Data: totalRate.Points,
 	}
 	summary.Graphs = append(summary.Graphs, graph)
 
+	if g, ok := filter.QuantileGraph("request_duration_seconds", 0.95, evalInterval); ok {
+		g.Title = fmt.Sprintf("%s: p95 request duration (seconds) for last %d seconds over %d seconds interval", metrics.Now(), last, evalInterval)
+		summary.Graphs = append(summary.Graphs, g)
+	}
+
 	currentValue := totalRate.Points[len(totalRate.Points)-1]
 	tb.Data = append(tb.Data, []string{"*", fmt.Sprintf("%f", currentValue)})
 
@@ -205,6 +542,68 @@ func (f *HTTPAccessLogFilter) Summary(evalInterval int64) printer.Summary {
 	}
 
 	summary.Tables = append(summary.Tables, tb)
+
+	if g, ok := filter.RateGraph("bytes_sent_total", evalInterval); ok {
+		g.Title = fmt.Sprintf("%s: bandwidth (bytes per second) for last %d seconds over %d seconds interval", metrics.Now(), last, evalInterval)
+		summary.Graphs = append(summary.Graphs, g)
+	}
+	if bw, ok := filter.RateTable("bandwidth (bytes per second) grouped by section", "bytes_sent_total", "section", evalInterval); ok {
+		summary.Tables = append(summary.Tables, bw)
+	}
+	if lt, ok := filter.QuantileTable("request duration (seconds) grouped by section", "section_request_duration_seconds", "section", evalInterval); ok {
+		if total, ok := filter.QuantileTable("", "request_duration_seconds", "", evalInterval); ok {
+			lt.Data = append(total.Data, lt.Data...)
+		}
+		summary.Tables = append(summary.Tables, lt)
+	}
+	for _, v := range f.values {
+		if vt, ok := filter.QuantileTable(fmt.Sprintf("$%s grouped by section", v.name), v.name, "section", evalInterval); ok {
+			summary.Tables = append(summary.Tables, vt)
+		}
+	}
+	if tc, ok := filter.RateTable("traffic by client class (requests per second)", "client_requests_total", "class", evalInterval); ok {
+		summary.Tables = append(summary.Tables, tc)
+	}
+	if tf, ok := filter.RateTable("traffic by client family (requests per second)", "client_requests_total", "family", evalInterval); ok {
+		summary.Tables = append(summary.Tables, tf)
+	}
+	if tn, ok := filter.RateTable("traffic by client network (requests per second)", "network_requests_total", "client_net", evalInterval); ok {
+		summary.Tables = append(summary.Tables, tn)
+	}
+	if tr, ok := filter.RateTable("traffic by referral (requests per second)", "referral_requests_total", "referral", evalInterval); ok {
+		summary.Tables = append(summary.Tables, tr)
+	}
+	window := fmt.Sprintf("last %s", topKWindow)
+	if dc, ok := filter.CardinalityTable("distinct clients of the "+window+" grouped by section", "section", distinctClients, topKWindow); ok {
+		summary.Tables = append(summary.Tables, dc)
+	}
+	if tc, ok := filter.TopTable("top clients of the "+window, "remote host", topClients, topN); ok {
+		summary.Tables = append(summary.Tables, tc)
+	}
+	if tu, ok := filter.TopTable("top URIs of the "+window, "uri", topURIs, topN); ok {
+		summary.Tables = append(summary.Tables, tu)
+	}
+	if ta, ok := filter.TopTable("top user agents of the "+window, "user agent", topUserAgents, topN); ok {
+		summary.Tables = append(summary.Tables, ta)
+	}
+	if tr, ok := filter.TopTable("top external referrers of the "+window, "referring domain", topReferrers, topN); ok {
+		summary.Tables = append(summary.Tables, tr)
+	}
+	if tc, ok := filter.TopTable("top countries of the "+window, "country", topCountries, topN); ok {
+		summary.Tables = append(summary.Tables, tc)
+	}
+	if ta, ok := filter.TopTable("top autonomous systems of the "+window, "AS", topASes, topN); ok {
+		summary.Tables = append(summary.Tables, ta)
+	}
+
+	title := fmt.Sprintf("response size (bytes) distribution for last %d seconds", last)
+	if hs, ok := filter.HistogramTable(title, "response_size_bytes", evalInterval); ok {
+		summary.Tables = append(summary.Tables, hs)
+	}
+	if ls, ok := filter.GaugeTable("last response size (bytes) grouped by section", "last_response_bytes", "section", evalInterval); ok {
+		summary.Tables = append(summary.Tables, ls)
+	}
+
 	return summary
 }
 
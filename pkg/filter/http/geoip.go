@@ -0,0 +1,38 @@
+package http
+
+import (
+	"github.com/almariah/ltop/pkg/filter"
+	"github.com/almariah/ltop/pkg/geoip"
+)
+
+// options of the HTTP filters configuring the GeoIP enrichment
+var geoipOptions = []filter.Option{
+	{
+		Name:  "geoip-database",
+		Usage: "comma separated MaxMind DB files (.mmdb) resolving clients to country and AS, e.g. GeoLite2-Country.mmdb,GeoLite2-ASN.mmdb",
+	},
+	{
+		Name:    "geoip-cache-size",
+		Usage:   "number of clients whose country and AS are cached",
+		Default: "10000",
+	},
+	{
+		Name:    "geoip-labels",
+		Usage:   "add the country and asn labels to request_total",
+		Default: "false",
+	},
+}
+
+// newGeoIPResolverFromOptions returns the resolver given by the filter
+// options, nil if no database is given.
+func newGeoIPResolverFromOptions(opts filter.Options) (*geoip.Resolver, error) {
+	paths := opts.List("geoip-database")
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	cacheSize, err := opts.Int("geoip-cache-size")
+	if err != nil {
+		return nil, err
+	}
+	return geoip.OpenResolver(cacheSize, paths...)
+}
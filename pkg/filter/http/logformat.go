@@ -0,0 +1,504 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/almariah/ltop/pkg/filter"
+)
+
+// Nicknames of the formats shipped with the Apache configuration.
+var apacheFormats = map[string]string{
+	"common":       `%h %l %u %t "%r" %>s %b`,
+	"combined":     `%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-agent}i"`,
+	"vhost_common": `%v %h %l %u %t "%r" %>s %b`,
+}
+
+// fieldSetter stores the text captured for a directive in the entry.
+type fieldSetter func(e *HTTPAccessLogEntry, v string) error
+
+// logFormatField is a single capture group of a compiled log format.
+type logFormatField struct {
+	name string
+	set  fieldSetter
+}
+
+// logFormat is a log format compiled into a regular expression, every
+// capture group of the expression is stored by the corresponding field.
+type logFormat struct {
+	re     *regexp.Regexp
+	fields []logFormatField
+	// a directive logs the time taken to serve the request
+	hasDuration bool
+}
+
+func (lf *logFormat) parse(entry string, e *HTTPAccessLogEntry) error {
+
+	matches := lf.re.FindStringSubmatch(entry)
+
+	if len(matches) != len(lf.fields)+1 {
+		return filter.ParseErrorf(filter.ReasonFormat, "could not parse line: '%s'", entry)
+	}
+
+	for i, f := range lf.fields {
+		if err := f.set(e, matches[i+1]); err != nil {
+			reason := filter.ReasonValue
+			if r := filter.ErrorReason(err); r != filter.ReasonUnknown {
+				reason = r
+			}
+			return filter.ParseErrorf(reason, "could not parse %s of line: '%s'; %s", f.name, entry, err)
+		}
+	}
+
+	e.setSection()
+
+	return nil
+}
+
+const (
+	stringPattern       = `(\S*)`
+	quotedStringPattern = `(.*?)`
+	intPattern          = `(-?\d+|-)`
+	uintPattern         = `(\d+|-)`
+	floatPattern        = `(-?\d+(?:\.\d+)?|-)`
+	statusPattern       = `(\d{3}|-)`
+	fracPattern         = `(\d+)`
+	timePattern         = `\[([^\]]+)\]`
+)
+
+// apacheDirective describes how a directive is matched and stored.
+type apacheDirective struct {
+	pattern string
+	set     fieldSetter
+	// unit of a directive logging the time taken to serve the request
+	durationUnit time.Duration
+}
+
+// CompileApacheLogFormat compiles an Apache LogFormat string (or one of the
+// common, combined and vhost_common nicknames) into a parser. Directives
+// which have no matching field in HTTPAccessLogEntry are stored in Fields
+// keyed by the directive, e.g. "%D" or "%{X-Request-Id}i".
+func CompileApacheLogFormat(format string) (*logFormat, error) {
+
+	if f, ok := apacheFormats[format]; ok {
+		format = f
+	}
+
+	var (
+		buffer      bytes.Buffer
+		fields      []logFormatField
+		hasDuration bool
+	)
+
+	buffer.WriteString("^")
+
+	for i := 0; i < len(format); {
+
+		c := format[i]
+
+		if c != '%' {
+			buffer.WriteString(regexp.QuoteMeta(string(c)))
+			i++
+			continue
+		}
+
+		start := i
+		i++
+		if i >= len(format) {
+			return nil, fmt.Errorf("log format %q: dangling %% at end of format", format)
+		}
+
+		if format[i] == '%' {
+			buffer.WriteString("%")
+			i++
+			continue
+		}
+
+		// skip the status code condition, e.g. %400,501{User-agent}i or %!200i
+		if format[i] == '!' {
+			i++
+		}
+		for i < len(format) && (format[i] >= '0' && format[i] <= '9' || format[i] == ',') {
+			i++
+		}
+
+		// redirection modifiers, e.g. %>s or %<s, both map to the same field
+		if i < len(format) && (format[i] == '<' || format[i] == '>') {
+			i++
+		}
+
+		var param string
+		if i < len(format) && format[i] == '{' {
+			end := strings.IndexByte(format[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("log format %q: unterminated { in directive at offset %d", format, start)
+			}
+			param = format[i+1 : i+end]
+			i += end + 1
+		}
+
+		// directives are single letters except the ^ti, ^to trailer directives
+		if i >= len(format) {
+			return nil, fmt.Errorf("log format %q: missing directive at offset %d", format, start)
+		}
+		letter := format[i : i+1]
+		if format[i] == '^' {
+			if i+2 >= len(format) {
+				return nil, fmt.Errorf("log format %q: incomplete directive at offset %d", format, start)
+			}
+			letter = format[i : i+3]
+		}
+		i += len(letter)
+
+		name := format[start:i]
+
+		d, err := apacheDirectiveFor(letter, param)
+		if err != nil {
+			return nil, fmt.Errorf("log format %q: directive %s: %s", format, name, err)
+		}
+
+		pattern := d.pattern
+		if pattern == stringPattern && quoted(format, start, i) {
+			pattern = quotedStringPattern
+		}
+		buffer.WriteString(pattern)
+
+		set := d.set
+		if set == nil {
+			set = setField(name)
+		}
+		if d.durationUnit != 0 {
+			set = withDuration(set, d.durationUnit)
+			hasDuration = true
+		}
+		fields = append(fields, logFormatField{name: name, set: set})
+	}
+
+	buffer.WriteString("$")
+
+	re, err := regexp.Compile(buffer.String())
+	if err != nil {
+		return nil, fmt.Errorf("log format %q: %s", format, err)
+	}
+
+	return &logFormat{
+		re:          re,
+		fields:      fields,
+		hasDuration: hasDuration,
+	}, nil
+}
+
+// quoted reports whether the directive at format[start:end] is enclosed in
+// double quotes, in which case it may contain spaces.
+func quoted(format string, start, end int) bool {
+	return start > 0 && format[start-1] == '"' && end < len(format) && format[end] == '"'
+}
+
+func apacheDirectiveFor(letter, param string) (apacheDirective, error) {
+
+	switch letter {
+	case "a", "h":
+		return apacheDirective{pattern: stringPattern, set: setRemoteHost}, nil
+	case "l":
+		return apacheDirective{pattern: stringPattern, set: setRemoteLogname}, nil
+	case "u":
+		return apacheDirective{pattern: stringPattern, set: setUser}, nil
+	case "t":
+		layout, err := strftimeLayout(param)
+		if err != nil {
+			return apacheDirective{}, err
+		}
+		switch layout {
+		case "msec_frac", "usec_frac":
+			return apacheDirective{pattern: fracPattern, set: setTimeFrac(layout)}, nil
+		}
+		pattern := timePattern
+		if param != "" {
+			pattern = quotedStringPattern
+		}
+		return apacheDirective{pattern: pattern, set: setTime(layout)}, nil
+	case "r":
+		return apacheDirective{pattern: quotedStringPattern, set: setRequestLine}, nil
+	case "m":
+		return apacheDirective{pattern: stringPattern, set: setMethod}, nil
+	case "U":
+		return apacheDirective{pattern: stringPattern, set: setURLPath}, nil
+	case "q":
+		return apacheDirective{pattern: stringPattern, set: setQueryString}, nil
+	case "H":
+		return apacheDirective{pattern: stringPattern, set: setProtocol}, nil
+	case "s":
+		return apacheDirective{pattern: statusPattern, set: setStatus}, nil
+	case "b", "B", "O":
+		return apacheDirective{pattern: uintPattern, set: setBytesSent}, nil
+	case "i":
+		if param == "" {
+			return apacheDirective{}, fmt.Errorf("missing header name")
+		}
+		switch strings.ToLower(param) {
+		case "referer":
+			return apacheDirective{pattern: stringPattern, set: setReferer}, nil
+		case "user-agent":
+			return apacheDirective{pattern: stringPattern, set: setUserAgent}, nil
+		}
+		return apacheDirective{pattern: stringPattern}, nil
+	case "C", "e", "n", "o", "^ti", "^to":
+		if param == "" {
+			return apacheDirective{}, fmt.Errorf("missing name")
+		}
+		return apacheDirective{pattern: stringPattern}, nil
+	case "A", "f", "L", "R", "v", "V", "X":
+		return apacheDirective{pattern: stringPattern}, nil
+	case "D":
+		return apacheDirective{pattern: uintPattern, durationUnit: time.Microsecond}, nil
+	case "I", "S", "k", "p", "P":
+		return apacheDirective{pattern: intPattern}, nil
+	case "T":
+		units := map[string]time.Duration{
+			"":   time.Second,
+			"s":  time.Second,
+			"ms": time.Millisecond,
+			"us": time.Microsecond,
+		}
+		unit, ok := units[param]
+		if !ok {
+			return apacheDirective{}, fmt.Errorf("unknown time unit %q", param)
+		}
+		return apacheDirective{pattern: floatPattern, durationUnit: unit}, nil
+	}
+
+	return apacheDirective{}, fmt.Errorf("unknown directive")
+}
+
+var strftimeLayouts = map[byte]string{
+	'a': "Mon",
+	'A': "Monday",
+	'b': "Jan",
+	'B': "January",
+	'd': "02",
+	'e': "_2",
+	'H': "15",
+	'I': "03",
+	'j': "002",
+	'm': "01",
+	'M': "04",
+	'p': "PM",
+	'S': "05",
+	'y': "06",
+	'Y': "2006",
+	'z': "-0700",
+	'Z': "MST",
+	'T': "15:04:05",
+	'D': "01/02/06",
+	'F': "2006-01-02",
+	'%': "%",
+}
+
+// strftimeLayout converts the format of a %{format}t directive to a time
+// layout. The sec, msec, usec, msec_frac and usec_frac formats are returned
+// unchanged.
+func strftimeLayout(format string) (string, error) {
+
+	if format == "" {
+		return "02/Jan/2006:15:04:05 -0700", nil
+	}
+
+	format = strings.TrimPrefix(strings.TrimPrefix(format, "begin:"), "end:")
+
+	switch format {
+	case "sec", "msec", "usec", "msec_frac", "usec_frac":
+		return format, nil
+	}
+
+	if !strings.Contains(format, "%") {
+		return "", fmt.Errorf("unsupported time format %s", format)
+	}
+
+	var layout bytes.Buffer
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			layout.WriteByte(format[i])
+			continue
+		}
+		i++
+		if i >= len(format) {
+			return "", fmt.Errorf("dangling %% in time format")
+		}
+		l, ok := strftimeLayouts[format[i]]
+		if !ok {
+			return "", fmt.Errorf("unsupported time format %%%c", format[i])
+		}
+		layout.WriteString(l)
+	}
+
+	return layout.String(), nil
+}
+
+func setRemoteHost(e *HTTPAccessLogEntry, v string) error {
+	e.RemoteHost = v
+	return nil
+}
+
+func setRemoteLogname(e *HTTPAccessLogEntry, v string) error {
+	e.RemoteLogname = v
+	return nil
+}
+
+func setUser(e *HTTPAccessLogEntry, v string) error {
+	e.User = v
+	return nil
+}
+
+func setTime(layout string) fieldSetter {
+	return func(e *HTTPAccessLogEntry, v string) error {
+		switch layout {
+		case "sec", "msec", "usec":
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return &filter.ParseError{Reason: filter.ReasonTime, Err: err}
+			}
+			switch layout {
+			case "sec":
+				e.Time = time.Unix(n, 0)
+			case "msec":
+				e.Time = time.Unix(0, n*int64(time.Millisecond))
+			case "usec":
+				e.Time = time.Unix(0, n*int64(time.Microsecond))
+			}
+			return nil
+		}
+		t, err := time.Parse(layout, v)
+		if err != nil {
+			return &filter.ParseError{Reason: filter.ReasonTime, Err: err}
+		}
+		e.Time = t.Add(e.timeFrac)
+		return nil
+	}
+}
+
+// setTimeFrac adds the milli or microseconds of %{msec_frac}t and
+// %{usec_frac}t to the time, which is set before or after by another %t.
+func setTimeFrac(layout string) fieldSetter {
+	unit := time.Millisecond
+	if layout == "usec_frac" {
+		unit = time.Microsecond
+	}
+	return func(e *HTTPAccessLogEntry, v string) error {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return &filter.ParseError{Reason: filter.ReasonTime, Err: err}
+		}
+		e.timeFrac = time.Duration(n) * unit
+		e.Time = e.Time.Add(e.timeFrac)
+		return nil
+	}
+}
+
+func setRequestLine(e *HTTPAccessLogEntry, v string) error {
+	parts := strings.Fields(v)
+	if len(parts) > 0 {
+		e.Method = parts[0]
+	}
+	if len(parts) > 1 {
+		e.URI = parts[1]
+	}
+	if len(parts) > 2 {
+		e.Protocol = parts[2]
+	}
+	return nil
+}
+
+func setMethod(e *HTTPAccessLogEntry, v string) error {
+	e.Method = v
+	return nil
+}
+
+// setURLPath sets the path of the URI, keeping a query string which is
+// already set by %q.
+func setURLPath(e *HTTPAccessLogEntry, v string) error {
+	e.URI = v + e.URI
+	return nil
+}
+
+func setQueryString(e *HTTPAccessLogEntry, v string) error {
+	e.URI += v
+	return nil
+}
+
+func setProtocol(e *HTTPAccessLogEntry, v string) error {
+	e.Protocol = v
+	return nil
+}
+
+func setStatus(e *HTTPAccessLogEntry, v string) error {
+	if v == "-" {
+		return nil
+	}
+	status, err := strconv.Atoi(v)
+	if err != nil {
+		return err
+	}
+	e.Status = status
+	return nil
+}
+
+func setBytesSent(e *HTTPAccessLogEntry, v string) error {
+	if v == "-" {
+		e.BytesSent = 0
+		return nil
+	}
+	bytesSent, err := strconv.Atoi(v)
+	if err != nil {
+		return err
+	}
+	e.BytesSent = bytesSent
+	return nil
+}
+
+func setReferer(e *HTTPAccessLogEntry, v string) error {
+	e.Referer = v
+	return nil
+}
+
+func setUserAgent(e *HTTPAccessLogEntry, v string) error {
+	e.UserAgent = v
+	return nil
+}
+
+// withDuration sets the duration of the entry to the value in the given
+// unit after set stored it. '-' means the duration is not available.
+func withDuration(set fieldSetter, unit time.Duration) fieldSetter {
+	return func(e *HTTPAccessLogEntry, v string) error {
+		if v == "-" {
+			return set(e, v)
+		}
+		d, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		if d < 0 {
+			return filter.ParseErrorf(filter.ReasonValue, "negative duration %s", v)
+		}
+		if err := set(e, v); err != nil {
+			return err
+		}
+		e.Duration = time.Duration(d * float64(unit))
+		e.HasDuration = true
+		return nil
+	}
+}
+
+// setField stores the value in the extra fields of the entry.
+func setField(name string) fieldSetter {
+	return func(e *HTTPAccessLogEntry, v string) error {
+		if e.Fields == nil {
+			e.Fields = map[string]string{}
+		}
+		e.Fields[name] = v
+		return nil
+	}
+}
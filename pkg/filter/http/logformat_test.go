@@ -0,0 +1,253 @@
+package http
+
+import (
+	"testing"
+	"time"
+
+	"github.com/almariah/ltop/pkg/filter"
+)
+
+func TestCompileApacheLogFormat(t *testing.T) {
+	lf, err := CompileApacheLogFormat(`%h %l %u %t "%r" %>s %b %D "%{X-Request-Id}i"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var e HTTPAccessLogEntry
+	line := `10.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /api/users?id=1 HTTP/1.1" 200 2326 1534 "f00 ba7"`
+	if err := lf.parse(line, &e); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := HTTPAccessLogEntry{
+		RemoteHost:    "10.0.0.1",
+		RemoteLogname: "-",
+		User:          "frank",
+		Time:          time.Date(2000, time.October, 10, 13, 55, 36, 0, time.FixedZone("", -7*3600)),
+		Method:        "GET",
+		URI:           "/api/users?id=1",
+		Section:       "/api",
+		Protocol:      "HTTP/1.1",
+		Status:        200,
+		BytesSent:     2326,
+	}
+	if e.RemoteHost != exp.RemoteHost || e.RemoteLogname != exp.RemoteLogname || e.User != exp.User ||
+		!e.Time.Equal(exp.Time) || e.Method != exp.Method || e.URI != exp.URI || e.Section != exp.Section ||
+		e.Protocol != exp.Protocol || e.Status != exp.Status || e.BytesSent != exp.BytesSent {
+		t.Fatalf("unexpected entry %+v", e)
+	}
+	if e.Fields["%D"] != "1534" {
+		t.Fatalf("unexpected %%D field %q", e.Fields["%D"])
+	}
+	if e.Fields["%{X-Request-Id}i"] != "f00 ba7" {
+		t.Fatalf("unexpected request id field %q", e.Fields["%{X-Request-Id}i"])
+	}
+	if !e.HasDuration || e.Duration != 1534*time.Microsecond {
+		t.Fatalf("unexpected duration %v", e.Duration)
+	}
+}
+
+func TestApacheLogFormatDuration(t *testing.T) {
+	tests := []struct {
+		format string
+		value  string
+		exp    time.Duration
+	}{
+		{`%h %T`, "2", 2 * time.Second},
+		{`%h %{s}T`, "1", time.Second},
+		{`%h %{ms}T`, "250", 250 * time.Millisecond},
+		{`%h %{us}T`, "1500", 1500 * time.Microsecond},
+		{`%h %D`, "42", 42 * time.Microsecond},
+	}
+
+	for _, test := range tests {
+		lf, err := CompileApacheLogFormat(test.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !lf.hasDuration {
+			t.Errorf("format %q: expected a duration", test.format)
+		}
+		var e HTTPAccessLogEntry
+		if err := lf.parse("10.0.0.1 "+test.value, &e); err != nil {
+			t.Fatal(err)
+		}
+		if !e.HasDuration || e.Duration != test.exp {
+			t.Errorf("format %q: expected duration %v, got %v", test.format, test.exp, e.Duration)
+		}
+	}
+
+	lf, err := CompileApacheLogFormat(`%h %D`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e HTTPAccessLogEntry
+	if err := lf.parse("10.0.0.1 -", &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.HasDuration {
+		t.Fatal("expected no duration for '-'")
+	}
+}
+
+func TestCompileApacheLogFormatCombined(t *testing.T) {
+	lf, err := CompileApacheLogFormat("combined")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var e HTTPAccessLogEntry
+	line := `109.169.248.247 - - [12/Dec/2015:18:25:11 +0100] "POST /administrator/index.php HTTP/1.1" 200 4494 "http://almhuette-raith.at/administrator/" "Mozilla/5.0 (Windows NT 6.0; rv:34.0) Gecko/20100101 Firefox/34.0"`
+	if err := lf.parse(line, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Referer != "http://almhuette-raith.at/administrator/" {
+		t.Fatalf("unexpected referer %q", e.Referer)
+	}
+	if e.UserAgent != "Mozilla/5.0 (Windows NT 6.0; rv:34.0) Gecko/20100101 Firefox/34.0" {
+		t.Fatalf("unexpected user agent %q", e.UserAgent)
+	}
+	if e.Section != "/administrator" {
+		t.Fatalf("unexpected section %q", e.Section)
+	}
+}
+
+func TestCompileApacheLogFormatErrors(t *testing.T) {
+	for _, format := range []string{
+		`%h %Z`,
+		`%h %{Referer`,
+		`%h %i`,
+		`%h %{%Q}t`,
+		`%h %{2006-01-02}t`,
+		`%h %{nsec_frac}t`,
+		`%h %`,
+	} {
+		if _, err := CompileApacheLogFormat(format); err == nil {
+			t.Errorf("expected error for format %q", format)
+		}
+	}
+}
+
+func TestCompileNginxLogFormat(t *testing.T) {
+	lf, err := CompileNginxLogFormat(`$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent $request_time $upstream_response_time`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var e HTTPAccessLogEntry
+	line := `192.168.1.7 - - [17/Oct/2026:10:01:02 +0000] "GET /static/app.js HTTP/2.0" 304 0 0.150 0.100, 0.040`
+	if err := lf.parse(line, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.RemoteHost != "192.168.1.7" || e.Method != "GET" || e.Section != "/static" || e.Status != 304 {
+		t.Fatalf("unexpected entry %+v", e)
+	}
+	if v, ok := e.Value("$request_time"); !ok || v != 0.150 {
+		t.Fatalf("unexpected $request_time %v", v)
+	}
+	if !lf.hasDuration || !e.HasDuration || e.Duration != 150*time.Millisecond {
+		t.Fatalf("unexpected duration %v", e.Duration)
+	}
+	if v, ok := e.Value("$upstream_response_time"); !ok || v < 0.1399 || v > 0.1401 {
+		t.Fatalf("unexpected $upstream_response_time %v", v)
+	}
+
+	e = HTTPAccessLogEntry{}
+	line = `192.168.1.7 - - [17/Oct/2026:10:01:02 +0000] "GET / HTTP/1.1" 502 157 0.003 -`
+	if err := lf.parse(line, &e); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := e.Value("$upstream_response_time"); ok {
+		t.Fatal("expected missing $upstream_response_time")
+	}
+
+	for line, exp := range map[string]float64{
+		`192.168.1.7 - - [17/Oct/2026:10:01:02 +0000] "GET / HTTP/1.1" 502 157 0.003 0.100, -`:   0.1,
+		`192.168.1.7 - - [17/Oct/2026:10:01:02 +0000] "GET / HTTP/1.1" 502 157 0.003 - : 0.002`:  0.002,
+		`192.168.1.7 - - [17/Oct/2026:10:01:02 +0000] "GET / HTTP/1.1" 502 157 0.003 -, 0.1 : -`: 0.1,
+	} {
+		e = HTTPAccessLogEntry{}
+		if err := lf.parse(line, &e); err != nil {
+			t.Fatal(err)
+		}
+		if v, ok := e.Value("$upstream_response_time"); !ok || v != exp {
+			t.Fatalf("unexpected $upstream_response_time %v of %q", v, line)
+		}
+	}
+
+	e = HTTPAccessLogEntry{}
+	line = `192.168.1.7 - - [17/Oct/2026:10:01:02 +0000] "GET / HTTP/1.1" 502 157 0.003 -, -`
+	if err := lf.parse(line, &e); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := e.Value("$upstream_response_time"); ok {
+		t.Fatal("expected missing $upstream_response_time")
+	}
+}
+
+func TestLogFormatNegativeBytes(t *testing.T) {
+	lf, err := CompileApacheLogFormat(`%h %b`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e HTTPAccessLogEntry
+	if err := lf.parse("10.0.0.1 -42", &e); err == nil {
+		t.Fatal("expected an error for negative bytes sent")
+	}
+}
+
+func TestLogFormatNegativeDuration(t *testing.T) {
+	apache := func(format string) (*logFormat, error) { return CompileApacheLogFormat(format) }
+	nginx := func(format string) (*logFormat, error) { return CompileNginxLogFormat(format) }
+
+	for _, test := range []struct {
+		compile func(string) (*logFormat, error)
+		format  string
+		line    string
+		reason  string
+	}{
+		// not matched by the unsigned pattern of %D
+		{apache, `%h %D`, "10.0.0.1 -1500", filter.ReasonFormat},
+		{apache, `%h %T`, "10.0.0.1 -1", filter.ReasonValue},
+		{apache, `%h %{ms}T`, "10.0.0.1 -250", filter.ReasonValue},
+		{nginx, `$remote_addr $request_time`, "10.0.0.1 -0.150", filter.ReasonValue},
+	} {
+		lf, err := test.compile(test.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var e HTTPAccessLogEntry
+		err = lf.parse(test.line, &e)
+		if err == nil || filter.ErrorReason(err) != test.reason {
+			t.Errorf("format %q: expected a %s error for %q, got %v", test.format, test.reason, test.line, err)
+		}
+		if e.HasDuration || len(e.Values) > 0 {
+			t.Errorf("format %q: unexpected duration %v and values %v", test.format, e.Duration, e.Values)
+		}
+	}
+}
+
+func TestLogFormatTimeFraction(t *testing.T) {
+	exp := time.Date(2026, 10, 17, 10, 1, 2, 0, time.UTC).Add(123 * time.Millisecond)
+
+	for _, test := range []struct {
+		format string
+		line   string
+	}{
+		{`%h [%{%d/%b/%Y:%H:%M:%S %z}t.%{msec_frac}t]`, "10.0.0.1 [17/Oct/2026:10:01:02 +0000.123]"},
+		{`%h %{msec_frac}t %{%d/%b/%Y:%H:%M:%S %z}t`, "10.0.0.1 123 17/Oct/2026:10:01:02 +0000"},
+		{`%h %{%Y-%m-%dT%T%z}t.%{usec_frac}t`, "10.0.0.1 2026-10-17T10:01:02+0000.123000"},
+	} {
+		lf, err := CompileApacheLogFormat(test.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var e HTTPAccessLogEntry
+		if err := lf.parse(test.line, &e); err != nil {
+			t.Fatal(err)
+		}
+		if !e.Time.Equal(exp) {
+			t.Errorf("format %q: unexpected time %v", test.format, e.Time)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package http
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/almariah/ltop/pkg/filter"
+)
+
+// options of the HTTP filters configuring the client_net label
+var networkOptions = []filter.Option{
+	{
+		Name:  "networks",
+		Usage: "file with one network per line, e.g. 10.1.0.0/16 office; the most specific network containing the client is its client_net",
+	},
+	{
+		Name:  "network-prefix",
+		Usage: "group public clients outside the networks by IPv4 and IPv6 prefix length, e.g. 24,64",
+	},
+}
+
+// client networks of addresses outside the configured networks
+const (
+	NetworkPrivate   = "private"
+	NetworkLoopback  = "loopback"
+	NetworkLinkLocal = "link-local"
+	NetworkPublic    = "public"
+	NetworkUnknown   = "unknown"
+)
+
+var privateNetworks = mustParseCIDRs(
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"100.64.0.0/10",
+	"fc00::/7",
+)
+
+// Network is a named range of client addresses.
+type Network struct {
+	Name string
+	Net  *net.IPNet
+}
+
+// NetworkRules derive the client_net label of a request from its remote
+// host.
+type NetworkRules struct {
+	// sorted from the most to the least specific
+	Networks []Network
+	// prefix length public clients are grouped by, 0 to group them as public
+	IPv4Prefix int
+	IPv6Prefix int
+}
+
+// defaultNetworkRules distinguishes private and public clients only
+var defaultNetworkRules = &NetworkRules{}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	var result []*net.IPNet
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		result = append(result, n)
+	}
+	return result
+}
+
+// NewNetworkRules returns rules with the given networks, sorted from the most
+// to the least specific.
+func NewNetworkRules(networks []Network, ipv4Prefix, ipv6Prefix int) (*NetworkRules, error) {
+
+	if ipv4Prefix < 0 || ipv4Prefix > 32 {
+		return nil, fmt.Errorf("invalid IPv4 prefix length %d", ipv4Prefix)
+	}
+	if ipv6Prefix < 0 || ipv6Prefix > 128 {
+		return nil, fmt.Errorf("invalid IPv6 prefix length %d", ipv6Prefix)
+	}
+
+	sorted := append([]Network{}, networks...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, _ := sorted[i].Net.Mask.Size()
+		b, _ := sorted[j].Net.Mask.Size()
+		return a > b
+	})
+
+	return &NetworkRules{
+		Networks:   sorted,
+		IPv4Prefix: ipv4Prefix,
+		IPv6Prefix: ipv6Prefix,
+	}, nil
+}
+
+// LoadNetworks reads a network table with one CIDR and name per line. Empty
+// lines and lines starting with # are ignored.
+func LoadNetworks(path string) ([]Network, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var networks []Network
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected a CIDR and a name", path, n)
+		}
+		_, ipNet, err := net.ParseCIDR(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, n, err)
+		}
+		networks = append(networks, Network{Name: fields[1], Net: ipNet})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return networks, nil
+}
+
+// newNetworkRulesFromOptions returns the network rules given by the filter
+// options, nil if the defaults are used.
+func newNetworkRulesFromOptions(opts filter.Options) (*NetworkRules, error) {
+
+	var networks []Network
+	if path := opts.String("networks"); path != "" {
+		var err error
+		if networks, err = LoadNetworks(path); err != nil {
+			return nil, err
+		}
+	}
+
+	var ipv4Prefix, ipv6Prefix int
+	if prefixes := opts.List("network-prefix"); len(prefixes) > 0 {
+		if len(prefixes) != 2 {
+			return nil, fmt.Errorf("network-prefix expects an IPv4 and an IPv6 prefix length, e.g. 24,64")
+		}
+		if _, err := fmt.Sscan(prefixes[0], &ipv4Prefix); err != nil {
+			return nil, fmt.Errorf("invalid IPv4 prefix length %q", prefixes[0])
+		}
+		if _, err := fmt.Sscan(prefixes[1], &ipv6Prefix); err != nil {
+			return nil, fmt.Errorf("invalid IPv6 prefix length %q", prefixes[1])
+		}
+	}
+
+	if len(networks) == 0 && ipv4Prefix == 0 && ipv6Prefix == 0 {
+		return nil, nil
+	}
+	return NewNetworkRules(networks, ipv4Prefix, ipv6Prefix)
+}
+
+// Network returns the client network of the remote host. Hosts which are no
+// IP address, e.g. with HostnameLookups, are unknown.
+func (r *NetworkRules) Network(host string) string {
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return NetworkUnknown
+	}
+
+	for _, n := range r.Networks {
+		if n.Net.Contains(ip) {
+			return n.Name
+		}
+	}
+
+	switch {
+	case ip.IsLoopback():
+		return NetworkLoopback
+	case ip.IsLinkLocalUnicast():
+		return NetworkLinkLocal
+	}
+	for _, n := range privateNetworks {
+		if n.Contains(ip) {
+			return NetworkPrivate
+		}
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		if r.IPv4Prefix > 0 {
+			return prefix(ip4, r.IPv4Prefix, 32)
+		}
+	} else if r.IPv6Prefix > 0 {
+		return prefix(ip, r.IPv6Prefix, 128)
+	}
+	return NetworkPublic
+}
+
+// prefix returns the network of the given prefix length containing ip in
+// CIDR notation.
+func prefix(ip net.IP, ones, bits int) string {
+	n := net.IPNet{IP: ip.Mask(net.CIDRMask(ones, bits)), Mask: net.CIDRMask(ones, bits)}
+	return n.String()
+}
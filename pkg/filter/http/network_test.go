@@ -0,0 +1,59 @@
+package http
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestNetwork(t *testing.T) {
+
+	file, err := ioutil.TempFile("", "networks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("10.0.0.0/8 internal\n# comment\n\n10.1.0.0/16 office\n203.0.113.0/24 cdn\n")
+	file.Close()
+
+	networks, err := LoadNetworks(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := NewNetworkRules(networks, 24, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		rules *NetworkRules
+		host  string
+		exp   string
+	}{
+		{defaultNetworkRules, "192.168.1.10", NetworkPrivate},
+		{defaultNetworkRules, "172.20.0.1", NetworkPrivate},
+		{defaultNetworkRules, "fd00::1", NetworkPrivate},
+		{defaultNetworkRules, "127.0.0.1", NetworkLoopback},
+		{defaultNetworkRules, "fe80::1", NetworkLinkLocal},
+		{defaultNetworkRules, "8.8.8.8", NetworkPublic},
+		{defaultNetworkRules, "example.com", NetworkUnknown},
+		{defaultNetworkRules, "-", NetworkUnknown},
+		{rules, "10.1.2.3", "office"},
+		{rules, "10.2.2.3", "internal"},
+		{rules, "203.0.113.7", "cdn"},
+		{rules, "192.168.1.10", NetworkPrivate},
+		{rules, "8.8.8.8", "8.8.8.0/24"},
+		{rules, "2001:db8:1:2:3::4", "2001:db8:1:2::/64"},
+	}
+
+	for _, test := range tests {
+		if got := test.rules.Network(test.host); got != test.exp {
+			t.Errorf("network of %q: expected %q, got %q", test.host, test.exp, got)
+		}
+	}
+
+	if _, err := NewNetworkRules(nil, 33, 64); err == nil {
+		t.Error("expected an error for an invalid IPv4 prefix length")
+	}
+}
@@ -0,0 +1,270 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/almariah/ltop/pkg/filter"
+)
+
+// the log format predefined by nginx
+const nginxCombinedFormat = `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent"`
+
+func init() {
+	filter.Register(filter.Registration{
+		Name:        "nginx-access-log",
+		Description: "HTTP access log written with an nginx log_format",
+		Options: append([]filter.Option{
+			{
+				Name:    "log-format",
+				Usage:   "nginx log_format definition of the log file",
+				Default: "combined",
+			},
+		}, append(valueOptions, sharedOptions()...)...),
+		New: func(opts filter.Options) (filter.Filter, error) {
+			f, err := NewNginxAccessLogFilter(opts.String("log-format"))
+			if err != nil {
+				return nil, err
+			}
+			if err := f.configure(opts); err != nil {
+				return nil, err
+			}
+			if f.values, err = newValueSummariesFromOptions(opts, f.format); err != nil {
+				return nil, err
+			}
+			return f, nil
+		},
+	})
+}
+
+// NewNginxAccessLogFilter returns a filter parsing lines with the given
+// nginx log_format definition.
+func NewNginxAccessLogFilter(format string) (*HTTPAccessLogFilter, error) {
+
+	lf, err := CompileNginxLogFormat(format)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HTTPAccessLogFilter{
+		format: lf,
+	}, nil
+}
+
+const (
+	// a comma or colon separated list of values as logged for every
+	// upstream server contacted, e.g. "0.012, 0.004"
+	upstreamValuesPattern = `((?:\d+(?:\.\d+)?|-)(?:\s*[,:]\s*(?:\d+(?:\.\d+)?|-))*)`
+)
+
+// nginx variables which are always numeric and stored in Values
+var nginxNumericVariables = map[string]bool{
+	"bytes_sent":               true,
+	"connection":               true,
+	"connection_requests":      true,
+	"content_length":           true,
+	"gzip_ratio":               true,
+	"pid":                      true,
+	"request_length":           true,
+	"request_time":             true,
+	"server_port":              true,
+	"upstream_bytes_received":  true,
+	"upstream_bytes_sent":      true,
+	"upstream_connect_time":    true,
+	"upstream_header_time":     true,
+	"upstream_response_length": true,
+	"upstream_response_time":   true,
+	"upstream_queue_time":      true,
+	"upstream_first_byte_time": true,
+	"upstream_session_time":    true,
+	"session_time":             true,
+	"remote_port":              true,
+}
+
+// CompileNginxLogFormat compiles an nginx log_format definition (or the
+// predefined combined format) into a parser. Numeric variables such as
+// $request_time and $upstream_response_time are stored in Values, other
+// variables without a dedicated field in Fields, both keyed by the variable
+// name including the leading $.
+func CompileNginxLogFormat(format string) (*logFormat, error) {
+
+	if format == "combined" {
+		format = nginxCombinedFormat
+	}
+
+	var (
+		buffer      bytes.Buffer
+		fields      []logFormatField
+		hasDuration bool
+	)
+
+	buffer.WriteString("^")
+
+	for i := 0; i < len(format); {
+
+		if format[i] != '$' {
+			buffer.WriteString(regexp.QuoteMeta(format[i : i+1]))
+			i++
+			continue
+		}
+
+		start := i
+		i++
+
+		var name string
+		if i < len(format) && format[i] == '{' {
+			end := strings.IndexByte(format[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("log format %q: unterminated { in variable at offset %d", format, start)
+			}
+			name = format[i+1 : i+end]
+			i += end + 1
+		} else {
+			j := i
+			for j < len(format) && isNginxVariableByte(format[j]) {
+				j++
+			}
+			name = format[i:j]
+			i = j
+		}
+
+		if name == "" {
+			return nil, fmt.Errorf("log format %q: missing variable name at offset %d", format, start)
+		}
+
+		pattern, set := nginxVariable(name)
+		if pattern == stringPattern && quoted(format, start, i) {
+			pattern = quotedStringPattern
+		}
+		buffer.WriteString(pattern)
+
+		fields = append(fields, logFormatField{name: "$" + name, set: set})
+		if name == "request_time" {
+			hasDuration = true
+		}
+	}
+
+	buffer.WriteString("$")
+
+	re, err := regexp.Compile(buffer.String())
+	if err != nil {
+		return nil, fmt.Errorf("log format %q: %s", format, err)
+	}
+
+	return &logFormat{
+		re:          re,
+		fields:      fields,
+		hasDuration: hasDuration,
+	}, nil
+}
+
+func isNginxVariableByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func nginxVariable(name string) (string, fieldSetter) {
+
+	switch name {
+	case "remote_addr", "realip_remote_addr":
+		return stringPattern, setRemoteHost
+	case "remote_user":
+		return stringPattern, setUser
+	case "time_local":
+		return `(\S+ [+\-]\d{4})`, setTime("02/Jan/2006:15:04:05 -0700")
+	case "time_iso8601":
+		return stringPattern, setTime(time.RFC3339)
+	case "msec":
+		return `(\d+(?:\.\d+)?)`, setMsecTime
+	case "request":
+		return quotedStringPattern, setRequestLine
+	case "request_method":
+		return stringPattern, setMethod
+	case "request_uri":
+		return stringPattern, setURI
+	case "uri", "document_uri":
+		return stringPattern, setURLPath
+	case "args", "query_string":
+		return stringPattern, setArgs
+	case "server_protocol":
+		return stringPattern, setProtocol
+	case "status":
+		return statusPattern, setStatus
+	case "body_bytes_sent":
+		return uintPattern, setBytesSent
+	case "http_referer":
+		return stringPattern, setReferer
+	case "http_user_agent":
+		return stringPattern, setUserAgent
+	case "request_time":
+		return floatPattern, withDuration(setValue("$request_time"), time.Second)
+	}
+
+	if nginxNumericVariables[name] {
+		if strings.HasPrefix(name, "upstream_") {
+			return upstreamValuesPattern, setValue("$" + name)
+		}
+		return floatPattern, setValue("$" + name)
+	}
+
+	return stringPattern, setField("$" + name)
+}
+
+func setMsecTime(e *HTTPAccessLogEntry, v string) error {
+	msec, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return &filter.ParseError{Reason: filter.ReasonTime, Err: err}
+	}
+	e.Time = time.Unix(0, int64(msec*float64(time.Second)))
+	return nil
+}
+
+func setURI(e *HTTPAccessLogEntry, v string) error {
+	e.URI = v
+	return nil
+}
+
+func setArgs(e *HTTPAccessLogEntry, v string) error {
+	if v == "" || v == "-" {
+		return nil
+	}
+	e.URI += "?" + v
+	return nil
+}
+
+// setValue stores the value in the numeric values of the entry. Lists of
+// values logged for several upstream servers are summed up, '-' means the
+// value is not available and is not stored.
+func setValue(name string) fieldSetter {
+	return func(e *HTTPAccessLogEntry, v string) error {
+		// upstream lists hold - for the upstreams which were not reached,
+		// the value is missing if no upstream was reached
+		var sum float64
+		var found bool
+		for _, s := range strings.FieldsFunc(v, func(r rune) bool {
+			return r == ',' || r == ':' || r == ' '
+		}) {
+			if s == "-" {
+				continue
+			}
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return err
+			}
+			sum += f
+			found = true
+		}
+		if !found {
+			return nil
+		}
+
+		if e.Values == nil {
+			e.Values = map[string]float64{}
+		}
+		e.Values[name] = sum
+		return nil
+	}
+}
@@ -0,0 +1,143 @@
+package http
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/almariah/ltop/pkg/filter"
+)
+
+// options of the HTTP filters configuring the referrer breakdown
+var referrerOptions = []filter.Option{
+	{
+		Name:  "site-hosts",
+		Usage: "comma separated hosts of the site, referrals from them are internal, e.g. example.com,*.example.com; if unset referrals from the requested host are internal, or from the most common referring host if the log format has no host field",
+	},
+}
+
+// kinds of referrals
+const (
+	ReferralDirect   = "direct"
+	ReferralInternal = "internal"
+	ReferralExternal = "external"
+)
+
+// fields of custom log formats holding the host the request was sent to
+var requestHostFields = []string{"%v", "%V", "%{Host}i", "$host", "$http_host", "$server_name"}
+
+// maximum number of referring hosts counted to infer the site host
+const maxReferrerHosts = 1000
+
+// ReferrerRules classify the referrers of requests.
+type ReferrerRules struct {
+	// hosts of the site, a leading "*." matches any subdomain
+	SiteHosts []string
+
+	// referring hosts counted to infer the site host if neither SiteHosts
+	// nor the requested host are known
+	mtx      sync.Mutex
+	hosts    map[string]int
+	siteHost string
+}
+
+// defaultReferrerRules treat referrals from the requested host as internal,
+// or from the most common referring host if the requested host is not logged
+var defaultReferrerRules = &ReferrerRules{}
+
+// newReferrerRulesFromOptions returns the referrer rules given by the filter
+// options, nil if the defaults are used.
+func newReferrerRulesFromOptions(opts filter.Options) *ReferrerRules {
+	hosts := opts.List("site-hosts")
+	if len(hosts) == 0 {
+		return nil
+	}
+	for i, h := range hosts {
+		hosts[i] = normalizeHost(h)
+	}
+	return &ReferrerRules{SiteHosts: hosts}
+}
+
+// Referral returns the kind of referral and the referring domain of the
+// request, "-" for direct requests. The domain is the host of the referrer
+// in lower case without port and "www." prefix.
+func (r *ReferrerRules) Referral(e *HTTPAccessLogEntry) (string, string) {
+
+	if e.Referer == "" || e.Referer == "-" {
+		return ReferralDirect, "-"
+	}
+
+	u, err := url.Parse(e.Referer)
+	if err != nil || u.Host == "" {
+		// e.g. about:blank or a bare path
+		return ReferralDirect, "-"
+	}
+	domain := normalizeHost(u.Host)
+
+	if r.internal(domain) {
+		return ReferralInternal, domain
+	}
+
+	var logged bool
+	for _, name := range requestHostFields {
+		host, ok := e.Fields[name]
+		if !ok || host == "-" {
+			continue
+		}
+		if normalizeHost(host) == domain {
+			return ReferralInternal, domain
+		}
+		logged = true
+	}
+
+	// e.g. the combined log format, most referrals of a site come from its
+	// own pages
+	if !logged && len(r.SiteHosts) == 0 && r.inferSiteHost(domain) == domain {
+		return ReferralInternal, domain
+	}
+
+	return ReferralExternal, domain
+}
+
+// inferSiteHost counts the referring host and returns the most common one.
+func (r *ReferrerRules) inferSiteHost(domain string) string {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.hosts == nil {
+		r.hosts = map[string]int{}
+	}
+	if _, ok := r.hosts[domain]; ok || len(r.hosts) < maxReferrerHosts {
+		r.hosts[domain]++
+	}
+	if r.hosts[domain] > r.hosts[r.siteHost] {
+		r.siteHost = domain
+	}
+	return r.siteHost
+}
+
+func (r *ReferrerRules) internal(domain string) bool {
+	for _, h := range r.SiteHosts {
+		if strings.HasPrefix(h, "*.") {
+			if strings.HasSuffix(domain, h[1:]) || domain == h[2:] {
+				return true
+			}
+			continue
+		}
+		if domain == h {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeHost returns the host in lower case without port and "www."
+// prefix.
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	return strings.TrimPrefix(host, "www.")
+}
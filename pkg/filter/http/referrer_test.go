@@ -0,0 +1,71 @@
+package http
+
+import (
+	"testing"
+)
+
+func TestReferral(t *testing.T) {
+
+	site := &ReferrerRules{SiteHosts: []string{"example.com", "*.example.org"}}
+	host := map[string]string{"%v": "ltop.io"}
+
+	tests := []struct {
+		rules    *ReferrerRules
+		referer  string
+		fields   map[string]string
+		referral string
+		domain   string
+	}{
+		{defaultReferrerRules, "-", nil, ReferralDirect, "-"},
+		{defaultReferrerRules, "", nil, ReferralDirect, "-"},
+		{defaultReferrerRules, "about:blank", nil, ReferralDirect, "-"},
+		{defaultReferrerRules, "https://www.Google.com/search?q=ltop", host, ReferralExternal, "google.com"},
+		{defaultReferrerRules, "http://example.com:8080/a", host, ReferralExternal, "example.com"},
+		{defaultReferrerRules, "http://example.com/a", map[string]string{"%v": "www.example.com"}, ReferralInternal, "example.com"},
+		{defaultReferrerRules, "http://example.com/a", map[string]string{"$host": "other.com"}, ReferralExternal, "example.com"},
+		{site, "https://www.example.com/blog?page=2", nil, ReferralInternal, "example.com"},
+		{site, "https://docs.example.org/", nil, ReferralInternal, "docs.example.org"},
+		{site, "https://example.org/", nil, ReferralInternal, "example.org"},
+		{site, "https://notexample.org/", nil, ReferralExternal, "notexample.org"},
+	}
+
+	for _, test := range tests {
+		e := HTTPAccessLogEntry{Referer: test.referer, Fields: test.fields}
+		referral, domain := test.rules.Referral(&e)
+		if referral != test.referral || domain != test.domain {
+			t.Errorf("referral of %q: expected %s %q, got %s %q", test.referer, test.referral, test.domain, referral, domain)
+		}
+	}
+}
+
+func TestReferralInferredSiteHost(t *testing.T) {
+
+	// the combined log format has no host field, the site host is the most
+	// common referring host
+	rules := &ReferrerRules{}
+
+	tests := []struct {
+		referer  string
+		referral string
+	}{
+		{"http://almhuette-raith.at/administrator/", ReferralInternal},
+		{"http://almhuette-raith.at/", ReferralInternal},
+		{"https://www.google.com/", ReferralExternal},
+		{"https://www.google.com/", ReferralExternal},
+		{"http://www.almhuette-raith.at/index.php", ReferralInternal},
+		{"-", ReferralDirect},
+	}
+
+	for _, test := range tests {
+		e := HTTPAccessLogEntry{Referer: test.referer}
+		if referral, _ := rules.Referral(&e); referral != test.referral {
+			t.Errorf("referral of %q: expected %s, got %s", test.referer, test.referral, referral)
+		}
+	}
+
+	// the requested host is used if it is logged
+	e := HTTPAccessLogEntry{Referer: "http://almhuette-raith.at/", Fields: map[string]string{"$host": "example.com"}}
+	if referral, _ := rules.Referral(&e); referral != ReferralExternal {
+		t.Errorf("expected external referral from another host, got %s", referral)
+	}
+}
@@ -0,0 +1,223 @@
+package http
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/almariah/ltop/pkg/filter"
+)
+
+// options of the HTTP filters configuring the section label
+var sectionOptions = []filter.Option{
+	{
+		Name:    "section-depth",
+		Usage:   "number of path segments of the section, 0 for the whole path",
+		Default: "1",
+	},
+	{
+		Name:    "normalize-ids",
+		Usage:   "replace numeric, UUID and hex path segments of the section by :id",
+		Default: "false",
+	},
+	{
+		Name:  "routes",
+		Usage: "file with one route per line, e.g. /users/:id/orders/*; the first matching route is the section",
+	},
+}
+
+var (
+	uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	// at least 8 hex digits, with a digit so words like "deadbeef" are kept
+	hexPattern   = regexp.MustCompile(`^[0-9a-fA-F]{8,}$`)
+	digitPattern = regexp.MustCompile(`[0-9]`)
+)
+
+// SectionRules derive the section label of a request from its URI.
+type SectionRules struct {
+	// number of path segments, 0 for the whole path
+	Depth int
+	// replace ID segments by :id
+	NormalizeIDs bool
+	// the first matching route is the section
+	Routes []Route
+}
+
+// defaultSectionRules is the first path segment as section
+var defaultSectionRules = &SectionRules{Depth: 1}
+
+// Route is a path pattern. A segment ":name" matches any single segment, a
+// last segment "*" matches the rest of the path.
+type Route struct {
+	Pattern  string
+	segments []string
+}
+
+func ParseRoute(pattern string) (Route, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return Route{}, fmt.Errorf("route %q does not start with /", pattern)
+	}
+	segments := splitPath(pattern)
+	for i, s := range segments {
+		if s == "*" && i != len(segments)-1 {
+			return Route{}, fmt.Errorf("route %q has * before its last segment", pattern)
+		}
+	}
+	return Route{Pattern: pattern, segments: segments}, nil
+}
+
+func (r Route) match(segments []string) bool {
+	for i, s := range r.segments {
+		if s == "*" {
+			return true
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if !strings.HasPrefix(s, ":") && s != segments[i] {
+			return false
+		}
+	}
+	return len(segments) == len(r.segments)
+}
+
+// LoadRoutes reads a route table with one route per line. Empty lines and
+// lines starting with # are ignored.
+func LoadRoutes(path string) ([]Route, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var routes []Route
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r, err := ParseRoute(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, n, err)
+		}
+		routes = append(routes, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
+// NewSectionRules returns the section rules given by the filter options, nil
+// if the defaults are used.
+func NewSectionRules(opts filter.Options) (*SectionRules, error) {
+
+	depth, err := opts.Int("section-depth")
+	if err != nil {
+		return nil, err
+	}
+	if depth < 0 {
+		return nil, fmt.Errorf("section-depth must not be negative")
+	}
+	normalize, err := opts.Bool("normalize-ids")
+	if err != nil {
+		return nil, err
+	}
+
+	rules := &SectionRules{
+		Depth:        depth,
+		NormalizeIDs: normalize,
+	}
+
+	if path := opts.String("routes"); path != "" {
+		if rules.Routes, err = LoadRoutes(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if rules.Depth == 1 && !rules.NormalizeIDs && len(rules.Routes) == 0 {
+		return nil, nil
+	}
+	return rules, nil
+}
+
+// splitPath returns the segments of an absolute path.
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// uriPath returns the path of a request URI without query and fragment. An
+// absolute URI as sent to proxies is reduced to its path.
+func uriPath(uri string) string {
+	if i := strings.IndexAny(uri, "?#"); i >= 0 {
+		uri = uri[:i]
+	}
+	for _, scheme := range []string{"http://", "https://"} {
+		if strings.HasPrefix(uri, scheme) {
+			uri = uri[len(scheme):]
+			if i := strings.Index(uri, "/"); i >= 0 {
+				return uri[i:]
+			}
+			return "/"
+		}
+	}
+	return uri
+}
+
+func isID(segment string) bool {
+	if segment == "" {
+		return false
+	}
+	if strings.Trim(segment, "0123456789") == "" {
+		return true
+	}
+	if uuidPattern.MatchString(segment) {
+		return true
+	}
+	return hexPattern.MatchString(segment) && digitPattern.MatchString(segment)
+}
+
+// Section returns the section of the request URI. URIs which are no path,
+// like "*" of OPTIONS requests or "-", are their own section.
+func (r *SectionRules) Section(uri string) string {
+
+	path := uriPath(uri)
+	if !strings.HasPrefix(path, "/") {
+		if path == "" {
+			return "-"
+		}
+		return path
+	}
+
+	segments := splitPath(path)
+
+	for _, route := range r.Routes {
+		if route.match(segments) {
+			return route.Pattern
+		}
+	}
+
+	if r.Depth > 0 && len(segments) > r.Depth {
+		segments = segments[:r.Depth]
+	}
+
+	if r.NormalizeIDs {
+		normalized := make([]string, len(segments))
+		for i, s := range segments {
+			if isID(s) {
+				s = ":id"
+			}
+			normalized[i] = s
+		}
+		segments = normalized
+	}
+
+	return "/" + strings.Join(segments, "/")
+}
@@ -0,0 +1,47 @@
+package http
+
+import (
+	"testing"
+)
+
+func TestSection(t *testing.T) {
+
+	users, _ := ParseRoute("/users/:user/orders/:order")
+	static, _ := ParseRoute("/static/*")
+
+	tests := []struct {
+		rules *SectionRules
+		uri   string
+		exp   string
+	}{
+		{defaultSectionRules, "/api/user?id=1", "/api"},
+		{defaultSectionRules, "/", "/"},
+		{defaultSectionRules, "/?q=1", "/"},
+		{defaultSectionRules, "*", "*"},
+		{defaultSectionRules, "-", "-"},
+		{defaultSectionRules, "", "-"},
+		{defaultSectionRules, "http://example.com/api/user", "/api"},
+		{&SectionRules{Depth: 2}, "/api/user/1", "/api/user"},
+		{&SectionRules{Depth: 0}, "/api/user/1#top", "/api/user/1"},
+		{&SectionRules{NormalizeIDs: true}, "/users/123/orders/9", "/users/:id/orders/:id"},
+		{&SectionRules{NormalizeIDs: true}, "/items/0f8fad5b-d9cb-469f-a165-70867728950e", "/items/:id"},
+		{&SectionRules{NormalizeIDs: true}, "/blobs/5e884898da28047151d0e56f8dc62927/deadbeef", "/blobs/:id/deadbeef"},
+		{&SectionRules{Routes: []Route{users, static}}, "/users/123/orders/9?x=1", "/users/:user/orders/:order"},
+		{&SectionRules{Routes: []Route{users, static}}, "/static/css/site.css", "/static/*"},
+		{&SectionRules{Depth: 1, Routes: []Route{users}}, "/users/123", "/users"},
+	}
+
+	for _, test := range tests {
+		if got := test.rules.Section(test.uri); got != test.exp {
+			t.Errorf("section of %q: expected %q, got %q", test.uri, test.exp, got)
+		}
+	}
+}
+
+func TestParseRoute(t *testing.T) {
+	for _, pattern := range []string{"users", "/static/*/css"} {
+		if _, err := ParseRoute(pattern); err == nil {
+			t.Errorf("expected an error for route %q", pattern)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sync"
+
+	"github.com/almariah/ltop/pkg/filter"
+)
+
+// options of the HTTP filters configuring the client classification
+var clientOptions = []filter.Option{
+	{
+		Name:  "user-agent-rules",
+		Usage: "JSON file of user agent rules checked before the built-in rules",
+	},
+	{
+		Name:    "client-labels",
+		Usage:   "add the client_class and device labels to request_total",
+		Default: "false",
+	},
+}
+
+// client classes
+const (
+	ClientBot     = "bot"
+	ClientBrowser = "browser"
+	ClientCLI     = "cli"
+	ClientOther   = "other"
+	ClientUnknown = "unknown"
+)
+
+// devices
+const (
+	DeviceMobile  = "mobile"
+	DeviceDesktop = "desktop"
+)
+
+// maximum number of classified user agents cached
+const userAgentCacheSize = 10000
+
+// UserAgentRule classifies the user agents matching Pattern. Device is
+// derived from the user agent if empty.
+type UserAgentRule struct {
+	Pattern string `json:"pattern"`
+	Class   string `json:"class"`
+	Family  string `json:"family"`
+	Device  string `json:"device"`
+
+	re *regexp.Regexp
+}
+
+// the built-in rules, the first matching rule classifies a user agent
+var defaultUserAgentRules = []UserAgentRule{
+	{Pattern: `Googlebot`, Class: ClientBot, Family: "Googlebot"},
+	{Pattern: `bingbot|BingPreview`, Class: ClientBot, Family: "Bingbot"},
+	{Pattern: `YandexBot|YandexImages`, Class: ClientBot, Family: "YandexBot"},
+	{Pattern: `Baiduspider`, Class: ClientBot, Family: "Baiduspider"},
+	{Pattern: `DuckDuckBot`, Class: ClientBot, Family: "DuckDuckBot"},
+	{Pattern: `Yahoo! Slurp`, Class: ClientBot, Family: "Yahoo! Slurp"},
+	{Pattern: `Applebot`, Class: ClientBot, Family: "Applebot"},
+	{Pattern: `AhrefsBot`, Class: ClientBot, Family: "AhrefsBot"},
+	{Pattern: `SemrushBot`, Class: ClientBot, Family: "SemrushBot"},
+	{Pattern: `MJ12bot`, Class: ClientBot, Family: "MJ12bot"},
+	{Pattern: `facebookexternalhit|Facebot`, Class: ClientBot, Family: "Facebook"},
+	{Pattern: `Twitterbot`, Class: ClientBot, Family: "Twitterbot"},
+	{Pattern: `(?i)bot\b|crawl|spider|slurp|scanner|archiver|headless`, Class: ClientBot, Family: "other"},
+	{Pattern: `^curl/`, Class: ClientCLI, Family: "curl"},
+	{Pattern: `^Wget/`, Class: ClientCLI, Family: "Wget"},
+	{Pattern: `^python-requests/|^Python-urllib/|^aiohttp/`, Class: ClientCLI, Family: "Python"},
+	{Pattern: `^Go-http-client/`, Class: ClientCLI, Family: "Go"},
+	{Pattern: `^libwww-perl/|^WWW-Mechanize/`, Class: ClientCLI, Family: "Perl"},
+	{Pattern: `^Java/|^Apache-HttpClient/|^okhttp/`, Class: ClientCLI, Family: "Java"},
+	{Pattern: `^PostmanRuntime/|^HTTPie/|^Insomnia/`, Class: ClientCLI, Family: "API client"},
+	{Pattern: `Edg(e|A|iOS)?/`, Class: ClientBrowser, Family: "Edge"},
+	{Pattern: `OPR/|Opera`, Class: ClientBrowser, Family: "Opera"},
+	{Pattern: `Chrome/|CriOS/`, Class: ClientBrowser, Family: "Chrome"},
+	{Pattern: `Firefox/|FxiOS/`, Class: ClientBrowser, Family: "Firefox"},
+	{Pattern: `Version/[\d.]+.*Safari/`, Class: ClientBrowser, Family: "Safari"},
+	{Pattern: `MSIE |Trident/`, Class: ClientBrowser, Family: "Internet Explorer"},
+}
+
+// defaultUserAgentClassifier classifies by the built-in rules
+var defaultUserAgentClassifier, _ = NewUserAgentClassifier(nil)
+
+var mobilePattern = regexp.MustCompile(`(?i)mobi|android|iphone|ipad|ipod|windows phone|blackberry`)
+
+// UserAgent is the classification of a user agent.
+type UserAgent struct {
+	Class  string
+	Family string
+	// mobile or desktop for browsers and others, "-" for bots and CLI tools
+	Device string
+}
+
+// UserAgentClassifier classifies user agents by rules. The classification
+// of recent user agents is cached.
+type UserAgentClassifier struct {
+	rules []UserAgentRule
+
+	mtx   sync.Mutex
+	cache map[string]UserAgent
+}
+
+// NewUserAgentClassifier returns a classifier checking the given rules
+// before the built-in ones.
+func NewUserAgentClassifier(rules []UserAgentRule) (*UserAgentClassifier, error) {
+
+	c := &UserAgentClassifier{
+		cache: map[string]UserAgent{},
+	}
+
+	for i, r := range append(append([]UserAgentRule{}, rules...), defaultUserAgentRules...) {
+		if r.Pattern == "" || r.Class == "" {
+			return nil, fmt.Errorf("user agent rule %d requires a pattern and a class", i)
+		}
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("user agent rule %d: invalid pattern: %s", i, err)
+		}
+		r.re = re
+		if r.Family == "" {
+			r.Family = ClientOther
+		}
+		c.rules = append(c.rules, r)
+	}
+
+	return c, nil
+}
+
+// LoadUserAgentRules reads a JSON list of rules, e.g.
+//
+//	[{"pattern": "^MyMonitor/", "class": "bot", "family": "MyMonitor"}]
+func LoadUserAgentRules(path string) ([]UserAgentRule, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []UserAgentRule
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return nil, fmt.Errorf("could not parse user agent rules %s: %s", path, err)
+	}
+	return rules, nil
+}
+
+// newUserAgentClassifierFromOptions returns the classifier given by the
+// filter options, nil if the built-in rules are used.
+func newUserAgentClassifierFromOptions(opts filter.Options) (*UserAgentClassifier, error) {
+	path := opts.String("user-agent-rules")
+	if path == "" {
+		return nil, nil
+	}
+	rules, err := LoadUserAgentRules(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewUserAgentClassifier(rules)
+}
+
+// Classify returns the classification of the user agent.
+func (c *UserAgentClassifier) Classify(ua string) UserAgent {
+
+	if ua == "" || ua == "-" {
+		return UserAgent{Class: ClientUnknown, Family: "-", Device: "-"}
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if result, ok := c.cache[ua]; ok {
+		return result
+	}
+
+	result := UserAgent{Class: ClientOther, Family: ClientOther}
+	for _, r := range c.rules {
+		if r.re.MatchString(ua) {
+			result = UserAgent{Class: r.Class, Family: r.Family, Device: r.Device}
+			break
+		}
+	}
+
+	if result.Device == "" {
+		switch {
+		case result.Class == ClientBot || result.Class == ClientCLI:
+			result.Device = "-"
+		case mobilePattern.MatchString(ua):
+			result.Device = DeviceMobile
+		default:
+			result.Device = DeviceDesktop
+		}
+	}
+
+	if len(c.cache) >= userAgentCacheSize {
+		c.cache = map[string]UserAgent{}
+	}
+	c.cache[ua] = result
+
+	return result
+}
@@ -0,0 +1,48 @@
+package http
+
+import (
+	"testing"
+)
+
+func TestClassifyUserAgent(t *testing.T) {
+
+	c, err := NewUserAgentClassifier([]UserAgentRule{
+		{Pattern: `^Pingdom`, Class: ClientBot, Family: "Pingdom"},
+		{Pattern: `^MyApp/`, Class: "app", Family: "MyApp"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ua  string
+		exp UserAgent
+	}{
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", UserAgent{ClientBot, "Googlebot", "-"}},
+		{"Mozilla/5.0 (compatible; SomeCrawler/1.0)", UserAgent{ClientBot, "other", "-"}},
+		{"curl/7.64.1", UserAgent{ClientCLI, "curl", "-"}},
+		{"python-requests/2.22.0", UserAgent{ClientCLI, "Python", "-"}},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/78.0.3904.108 Safari/537.36", UserAgent{ClientBrowser, "Chrome", DeviceDesktop}},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/78.0.3904.108 Safari/537.36 Edg/78.0.276.42", UserAgent{ClientBrowser, "Edge", DeviceDesktop}},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 13_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.0.3 Mobile/15E148 Safari/604.1", UserAgent{ClientBrowser, "Safari", DeviceMobile}},
+		{"Mozilla/5.0 (Android 10; Mobile; rv:70.0) Gecko/70.0 Firefox/70.0", UserAgent{ClientBrowser, "Firefox", DeviceMobile}},
+		{"Pingdom.com_bot_version_1.4", UserAgent{ClientBot, "Pingdom", "-"}},
+		{"MyApp/1.2 (iPhone)", UserAgent{"app", "MyApp", DeviceMobile}},
+		{"Mozilla/4.0", UserAgent{ClientOther, ClientOther, DeviceDesktop}},
+		{"-", UserAgent{ClientUnknown, "-", "-"}},
+		{"", UserAgent{ClientUnknown, "-", "-"}},
+	}
+
+	for _, test := range tests {
+		// the second classification is cached
+		for i := 0; i < 2; i++ {
+			if got := c.Classify(test.ua); got != test.exp {
+				t.Errorf("classification of %q: expected %+v, got %+v", test.ua, test.exp, got)
+			}
+		}
+	}
+
+	if _, err := NewUserAgentClassifier([]UserAgentRule{{Pattern: "(", Class: ClientBot}}); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+}
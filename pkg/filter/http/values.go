@@ -0,0 +1,71 @@
+package http
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/almariah/ltop/pkg/filter"
+	"github.com/almariah/ltop/pkg/metrics"
+)
+
+// options of the nginx filter observing numeric variables
+var valueOptions = []filter.Option{
+	{
+		Name:  "observe",
+		Usage: "comma separated numeric variables of the log format observed into quantile summaries per section, e.g. $upstream_response_time,$request_length",
+	},
+}
+
+// valueSummary observes a numeric variable of the entries, stored in their
+// Values, into a quantile summary named after the variable.
+type valueSummary struct {
+	name     string
+	variable string
+	vec      *metrics.QuantileSummaryVec
+}
+
+// newValueSummariesFromOptions returns the summaries of the variables given
+// by the observe option, which have to be numeric variables of the format.
+func newValueSummariesFromOptions(opts filter.Options, lf *logFormat) ([]valueSummary, error) {
+
+	var result []valueSummary
+
+	for _, v := range opts.List("observe") {
+		name := strings.TrimPrefix(v, "$")
+		if !nginxNumericVariables[name] {
+			return nil, fmt.Errorf("%s is not a numeric variable", v)
+		}
+		if !lf.hasField("$" + name) {
+			return nil, fmt.Errorf("%s is not part of the log format", v)
+		}
+
+		result = append(result, valueSummary{
+			name:     name,
+			variable: "$" + name,
+			vec: metrics.NewQuantileSummaryVec(metrics.QuantileSummaryOpts{
+				Name:       name,
+				Help:       fmt.Sprintf("Quantiles of $%s of the last 10 minutes broken out for each section.", name),
+				Objectives: durationObjectives,
+			}, []string{"section"}),
+		})
+	}
+
+	return result, nil
+}
+
+// observe observes the variable of the entry if it was logged.
+func (s valueSummary) observe(e *HTTPAccessLogEntry) {
+	if v, ok := e.Value(s.variable); ok {
+		s.vec.WithLabelValues(e.Section).ObserveAt(e.Time, v)
+	}
+}
+
+// hasField reports whether the log format has a field of the given name.
+func (lf *logFormat) hasField(name string) bool {
+	for _, f := range lf.fields {
+		if f.name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package http
+
+import (
+	"testing"
+	"time"
+
+	"github.com/almariah/ltop/pkg/filter"
+	"github.com/almariah/ltop/pkg/metrics"
+)
+
+func TestObserveValues(t *testing.T) {
+
+	f, err := filter.New("nginx-access-log", map[string]string{
+		"log-format": `$remote_addr [$time_local] "$request" $status $upstream_response_time`,
+		"observe":    "$upstream_response_time",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nginx := f.(*HTTPAccessLogFilter)
+
+	for _, line := range []string{
+		`10.0.0.1 [17/Oct/2026:10:01:02 +0000] "GET /api/users HTTP/1.1" 200 0.100`,
+		`10.0.0.1 [17/Oct/2026:10:01:03 +0000] "GET /api/users HTTP/1.1" 502 0.200, -`,
+		`10.0.0.1 [17/Oct/2026:10:01:04 +0000] "GET /api/users HTTP/1.1" 502 -`,
+	} {
+		if err := nginx.HandleEntry(time.Now(), line); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ch := make(chan metrics.Metric, 10)
+	go func() {
+		nginx.values[0].vec.Collect(ch)
+		close(ch)
+	}()
+
+	// the summary of /api holds 0.1 and 0.2, the line without upstream
+	// response is not observed
+	quantiles := map[string]float64{}
+	for m := range ch {
+		for _, l := range m.Labels() {
+			if l.Name == "quantile" {
+				quantiles[l.Value] = m.Value()
+			}
+		}
+	}
+	if quantiles["0.5"] != 0.1 || quantiles["0.99"] != 0.2 {
+		t.Fatalf("unexpected quantiles %v", quantiles)
+	}
+
+	for _, observe := range []string{"$remote_addr", "$request_length"} {
+		_, err := filter.New("nginx-access-log", map[string]string{
+			"log-format": `$remote_addr $request_time`,
+			"observe":    observe,
+		})
+		if err == nil {
+			t.Errorf("expected an error observing %s", observe)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package json
+
+import (
+	"bytes"
+	encjson "encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/almariah/ltop/pkg/filter"
+)
+
+func init() {
+	filter.Register(filter.Registration{
+		Name:        "json",
+		Description: "structured logs with one JSON object per line",
+		Options: append([]filter.Option{
+			{
+				Name:  "labels",
+				Usage: "comma separated label=path mappings of fields used as labels, nested keys are separated by '.'",
+			},
+			{
+				Name:  "counters",
+				Usage: "comma separated metric=path mappings of numeric fields added to counters",
+			},
+			{
+				Name:  "time-field",
+				Usage: "path of the field holding the event timestamp",
+			},
+			{
+				Name:    "time-layout",
+				Usage:   "layout of the event timestamp; a Go layout, rfc3339, clf, unix, unix_ms, ...",
+				Default: "rfc3339",
+			},
+			{
+				Name:  "group-by",
+				Usage: "label the summary tables are grouped by (default the first label)",
+			},
+		}, append(filter.ObserveOptions, filter.InFlightOptions...)...),
+		New: func(opts filter.Options) (filter.Filter, error) {
+			return NewJSONFilter(opts)
+		},
+	})
+}
+
+type JSONFilter struct {
+	*filter.Structured
+}
+
+func NewJSONFilter(opts filter.Options) (*JSONFilter, error) {
+	s, err := filter.NewStructured(opts, "counters")
+	if err != nil {
+		return nil, err
+	}
+	return &JSONFilter{Structured: s}, nil
+}
+
+// lookup returns the value at the given path of a decoded object. Keys
+// containing dots are matched before descending into nested objects.
+func lookup(v interface{}, path string) (interface{}, bool) {
+
+	switch o := v.(type) {
+	case map[string]interface{}:
+		if value, ok := o[path]; ok {
+			return value, true
+		}
+		i := strings.Index(path, ".")
+		for i > 0 {
+			if value, ok := o[path[:i]]; ok {
+				if r, ok := lookup(value, path[i+1:]); ok {
+					return r, true
+				}
+			}
+			next := strings.Index(path[i+1:], ".")
+			if next < 0 {
+				break
+			}
+			i += next + 1
+		}
+	case []interface{}:
+		head := path
+		tail := ""
+		if i := strings.Index(path, "."); i > 0 {
+			head, tail = path[:i], path[i+1:]
+		}
+		idx, err := strconv.Atoi(head)
+		if err != nil || idx < 0 || idx >= len(o) {
+			return nil, false
+		}
+		if tail == "" {
+			return o[idx], true
+		}
+		return lookup(o[idx], tail)
+	}
+
+	return nil, false
+}
+
+func toString(v interface{}) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	case encjson.Number:
+		return s.String()
+	case bool:
+		return strconv.FormatBool(s)
+	}
+	b, _ := encjson.Marshal(v)
+	return string(b)
+}
+
+func (f *JSONFilter) HandleEntry(time time.Time, entry string) error {
+
+	dec := encjson.NewDecoder(bytes.NewReader([]byte(entry)))
+	dec.UseNumber()
+
+	var o map[string]interface{}
+	if err := dec.Decode(&o); err != nil || dec.More() {
+		return filter.ParseErrorf(filter.ReasonFormat, "invalid JSON line: '%s'", entry)
+	}
+
+	return f.HandleFields(time, entry, func(field string) (string, bool) {
+		v, ok := lookup(o, field)
+		return toString(v), ok
+	})
+}
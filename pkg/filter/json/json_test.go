@@ -0,0 +1,152 @@
+package json
+
+import (
+	"testing"
+	"time"
+
+	"github.com/almariah/ltop/pkg/filter"
+	"github.com/almariah/ltop/pkg/metrics"
+)
+
+func TestHandleEntry(t *testing.T) {
+
+	f, err := NewJSONFilter(filter.Options{
+		"labels":      "method=req.method,status=res.status",
+		"counters":    "bytes=res.bytes,duration=res.duration",
+		"time-field":  "ts",
+		"time-layout": "rfc3339",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		line   string
+		reason string
+	}{
+		{`{"ts":"2019-05-01T10:00:00Z","req":{"method":"GET"},"res":{"status":200,"bytes":512,"duration":"15ms"}}`, ""},
+		{`{"ts":"2019-05-01T10:00:01Z","req":{"method":"GET"},"res":{"status":200,"bytes":"1024"}}`, ""},
+		{`{"ts":"2019-05-01T10:00:02Z","req":{"method":"POST"},"res":{"status":500}}`, ""},
+		{`{"ts":"2019-05-01T10:00:03Z","req":{"method":"GET"}`, filter.ReasonFormat},
+		{`{"ts":"2019-05-01T10:00:03Z"} {}`, filter.ReasonFormat},
+		{`GET /index.html`, filter.ReasonFormat},
+		{`{"req":{"method":"GET"}}`, filter.ReasonMissingField},
+		{`{"ts":"yesterday"}`, filter.ReasonTime},
+		{`{"ts":"2019-05-01T10:00:04Z","res":{"bytes":"many"}}`, filter.ReasonValue},
+		{`{"ts":"2019-05-01T10:00:04Z","res":{"bytes":-1}}`, filter.ReasonValue},
+	}
+
+	for _, test := range tests {
+		err := f.HandleEntry(time.Now(), test.line)
+		if test.reason == "" {
+			if err != nil {
+				t.Errorf("unexpected error for %s: %v", test.line, err)
+			}
+			continue
+		}
+		if err == nil || filter.ErrorReason(err) != test.reason {
+			t.Errorf("expected %s error for %s, got %v", test.reason, test.line, err)
+		}
+	}
+
+	value := func(vec *metrics.CounterVec, lvs ...string) float64 {
+		return vec.WithLabelValues(lvs...).(metrics.Metric).Value()
+	}
+
+	expected := []struct {
+		vec   *metrics.CounterVec
+		lvs   []string
+		value float64
+	}{
+		{f.EntryCounter, []string{"GET", "200"}, 2},
+		{f.EntryCounter, []string{"POST", "500"}, 1},
+		{f.Counters[0].Vec, []string{"GET", "200"}, 1536},
+		{f.Counters[1].Vec, []string{"GET", "200"}, 0.015},
+	}
+
+	for _, e := range expected {
+		if got := value(e.vec, e.lvs...); got != e.value {
+			t.Errorf("expected %v for %v, got %v", e.value, e.lvs, got)
+		}
+	}
+
+	if got, ok := f.LastEventTime(); !ok || !got.Equal(time.Date(2019, 5, 1, 10, 0, 4, 0, time.UTC)) {
+		t.Errorf("unexpected last event time %v", got)
+	}
+}
+
+func TestHandleEntryObserve(t *testing.T) {
+
+	f, err := NewJSONFilter(filter.Options{
+		"labels":  "method",
+		"observe": "latency=res.duration",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{
+		`{"method":"GET","res":{"duration":0.1}}`,
+		`{"method":"GET","res":{"duration":"200ms"}}`,
+		`{"method":"GET"}`,
+	} {
+		if err := f.HandleEntry(time.Now(), line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.HandleEntry(time.Now(), `{"method":"GET","res":{"duration":"slow"}}`); filter.ErrorReason(err) != filter.ReasonValue {
+		t.Errorf("expected value error, got %v", err)
+	}
+
+	ch := make(chan metrics.Metric, 10)
+	go func() {
+		f.Observed[0].Vec.Collect(ch)
+		close(ch)
+	}()
+
+	// the entry without duration is not observed, the rejected entry is
+	// neither observed nor counted
+	quantiles := map[string]float64{}
+	for m := range ch {
+		for _, l := range m.Labels() {
+			if l.Name == "quantile" {
+				quantiles[l.Value] = m.Value()
+			}
+		}
+	}
+	if quantiles["0.5"] != 0.1 || quantiles["0.99"] != 0.2 {
+		t.Errorf("unexpected quantiles %v", quantiles)
+	}
+	if got := f.EntryCounter.WithLabelValues("GET").(metrics.Metric).Value(); got != 3 {
+		t.Errorf("expected 3 entries, got %v", got)
+	}
+}
+
+func TestLookup(t *testing.T) {
+
+	o := map[string]interface{}{
+		"a.b": "dotted",
+		"a":   map[string]interface{}{"c": "nested"},
+		"l":   []interface{}{"x", map[string]interface{}{"y": "z"}},
+	}
+
+	tests := []struct {
+		path  string
+		value interface{}
+		ok    bool
+	}{
+		{"a.b", "dotted", true},
+		{"a.c", "nested", true},
+		{"l.0", "x", true},
+		{"l.1.y", "z", true},
+		{"l.2", nil, false},
+		{"a.d", nil, false},
+	}
+
+	for _, test := range tests {
+		v, ok := lookup(o, test.path)
+		if v != test.value || ok != test.ok {
+			t.Errorf("lookup of %s: expected %v %v, got %v %v", test.path, test.value, test.ok, v, ok)
+		}
+	}
+}
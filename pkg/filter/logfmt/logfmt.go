@@ -0,0 +1,74 @@
+package logfmt
+
+import (
+	"strings"
+	"time"
+
+	"github.com/almariah/ltop/pkg/filter"
+	"github.com/go-logfmt/logfmt"
+)
+
+func init() {
+	filter.Register(filter.Registration{
+		Name:        "logfmt",
+		Description: "structured logs with key=value pairs per line",
+		Options: append([]filter.Option{
+			{
+				Name:  "labels",
+				Usage: "comma separated label=key mappings of keys used as labels",
+			},
+			{
+				Name:  "values",
+				Usage: "comma separated metric=key mappings of numeric keys added to counters, durations like 12ms are counted in seconds",
+			},
+			{
+				Name:  "time-field",
+				Usage: "key holding the event timestamp",
+			},
+			{
+				Name:    "time-layout",
+				Usage:   "layout of the event timestamp; a Go layout, rfc3339, clf, unix, unix_ms, ...",
+				Default: "rfc3339",
+			},
+			{
+				Name:  "group-by",
+				Usage: "label the summary tables are grouped by (default the first label)",
+			},
+		}, append(filter.ObserveOptions, filter.InFlightOptions...)...),
+		New: func(opts filter.Options) (filter.Filter, error) {
+			return NewLogfmtFilter(opts)
+		},
+	})
+}
+
+type LogfmtFilter struct {
+	*filter.Structured
+}
+
+func NewLogfmtFilter(opts filter.Options) (*LogfmtFilter, error) {
+	s, err := filter.NewStructured(opts, "values")
+	if err != nil {
+		return nil, err
+	}
+	return &LogfmtFilter{Structured: s}, nil
+}
+
+func (f *LogfmtFilter) HandleEntry(time time.Time, entry string) error {
+
+	keyvals := map[string]string{}
+
+	dec := logfmt.NewDecoder(strings.NewReader(entry))
+	for dec.ScanRecord() {
+		for dec.ScanKeyval() {
+			keyvals[string(dec.Key())] = string(dec.Value())
+		}
+	}
+	if err := dec.Err(); err != nil {
+		return filter.ParseErrorf(filter.ReasonFormat, "could not parse line: '%s'; %s", entry, err)
+	}
+
+	return f.HandleFields(time, entry, func(field string) (string, bool) {
+		v, ok := keyvals[field]
+		return v, ok
+	})
+}
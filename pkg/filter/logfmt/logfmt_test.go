@@ -0,0 +1,132 @@
+package logfmt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/almariah/ltop/pkg/filter"
+	"github.com/almariah/ltop/pkg/metrics"
+)
+
+func TestHandleEntry(t *testing.T) {
+
+	f, err := NewLogfmtFilter(filter.Options{
+		"labels":      "method,status",
+		"values":      "bytes,latency=took",
+		"time-field":  "ts",
+		"time-layout": "rfc3339",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		line   string
+		reason string
+	}{
+		{`ts=2019-05-01T10:00:00Z method=GET status=200 bytes=512 took=15ms`, ""},
+		{`ts=2019-05-01T10:00:01Z method=GET status=200 bytes=1024 msg="served request"`, ""},
+		{`ts=2019-05-01T10:00:02Z method=POST status=500`, ""},
+		{`ts=2019-05-01T10:00:03Z msg="unterminated`, filter.ReasonFormat},
+		{`method=GET status=200`, filter.ReasonMissingField},
+		{`ts=yesterday method=GET`, filter.ReasonTime},
+		{`ts=2019-05-01T10:00:04Z bytes=many`, filter.ReasonValue},
+		{`ts=2019-05-01T10:00:04Z bytes=-1`, filter.ReasonValue},
+	}
+
+	for _, test := range tests {
+		err := f.HandleEntry(time.Now(), test.line)
+		if test.reason == "" {
+			if err != nil {
+				t.Errorf("unexpected error for %s: %v", test.line, err)
+			}
+			continue
+		}
+		if err == nil || filter.ErrorReason(err) != test.reason {
+			t.Errorf("expected %s error for %s, got %v", test.reason, test.line, err)
+		}
+	}
+
+	value := func(vec *metrics.CounterVec, lvs ...string) float64 {
+		return vec.WithLabelValues(lvs...).(metrics.Metric).Value()
+	}
+
+	expected := []struct {
+		vec   *metrics.CounterVec
+		lvs   []string
+		value float64
+	}{
+		{f.EntryCounter, []string{"GET", "200"}, 2},
+		{f.EntryCounter, []string{"POST", "500"}, 1},
+		{f.Counters[0].Vec, []string{"GET", "200"}, 1536},
+		{f.Counters[1].Vec, []string{"GET", "200"}, 0.015},
+	}
+
+	for _, e := range expected {
+		if got := value(e.vec, e.lvs...); got != e.value {
+			t.Errorf("expected %v for %v, got %v", e.value, e.lvs, got)
+		}
+	}
+
+	if f.Counters[1].Name != "latency_total" {
+		t.Errorf("unexpected counter name %s", f.Counters[1].Name)
+	}
+}
+
+func TestInFlight(t *testing.T) {
+
+	f, err := NewLogfmtFilter(filter.Options{
+		"in-flight-field": "msg",
+		"in-flight-start": "started",
+		"in-flight-end":   "finished",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		line     string
+		inFlight float64
+	}{
+		// the end of a request started before the log was read
+		{`msg=finished id=0`, 0},
+		{`msg=started id=1`, 1},
+		{`msg=started id=2`, 2},
+		{`msg="cache miss" id=2`, 2},
+		{`msg=finished id=1`, 1},
+		{`id=3`, 1},
+	} {
+		if err := f.HandleEntry(time.Now(), test.line); err != nil {
+			t.Fatal(err)
+		}
+		if got := f.InFlight.(metrics.Metric).Value(); got != test.inFlight {
+			t.Fatalf("expected %v requests in flight after %s, got %v", test.inFlight, test.line, got)
+		}
+	}
+
+	if _, err := NewLogfmtFilter(filter.Options{"in-flight-field": "msg", "in-flight-start": "started"}); err == nil {
+		t.Fatal("expected an error without in-flight-end")
+	}
+}
+
+func TestHandleEntryRejectedValue(t *testing.T) {
+
+	f, err := NewLogfmtFilter(filter.Options{
+		"labels": "method",
+		"values": "bytes,took",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the valid bytes are not counted as took is rejected
+	if err := f.HandleEntry(time.Now(), `method=GET bytes=512 took=soon`); filter.ErrorReason(err) != filter.ReasonValue {
+		t.Fatalf("expected a value error, got %v", err)
+	}
+
+	for _, vec := range []*metrics.CounterVec{f.EntryCounter, f.Counters[0].Vec, f.Counters[1].Vec} {
+		if got := vec.WithLabelValues("GET").(metrics.Metric).Value(); got != 0 {
+			t.Fatalf("expected the rejected line not to be counted, got %v", got)
+		}
+	}
+}
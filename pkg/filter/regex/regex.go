@@ -0,0 +1,307 @@
+package regex
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sync/atomic"
+	"time"
+
+	"github.com/almariah/ltop/pkg/filter"
+	"github.com/almariah/ltop/pkg/metrics"
+	"github.com/almariah/ltop/pkg/printer"
+)
+
+func init() {
+	filter.Register(filter.Registration{
+		Name:        "regex",
+		Description: "lines matched by a regular expression with named groups, configured from a JSON file",
+		Options: []filter.Option{
+			{
+				Name:  "config",
+				Usage: "path of the JSON configuration file",
+			},
+		},
+		New: func(opts filter.Options) (filter.Filter, error) {
+			path := opts.String("config")
+			if path == "" {
+				return nil, fmt.Errorf("regex filter requires the config option")
+			}
+			config, err := LoadConfig(path)
+			if err != nil {
+				return nil, err
+			}
+			return NewRegexFilter(config)
+		},
+	})
+}
+
+// TimeConfig declares the group holding the event timestamp.
+type TimeConfig struct {
+	Group  string `json:"group"`
+	Layout string `json:"layout"`
+}
+
+// MetricConfig declares a counter emitted for every matched line.
+type MetricConfig struct {
+	Name string `json:"name"`
+	Help string `json:"help"`
+	// Value is the group added to the counter, the counter is incremented
+	// by one if it is empty.
+	Value string `json:"value"`
+	// Labels is the subset of the declared labels used by the counter,
+	// all declared labels are used if it is empty.
+	Labels []string `json:"labels"`
+}
+
+// Config is the configuration of the regex filter, e.g.
+//
+//	{
+//	  "pattern": "^(?P<method>\\S+) (?P<path>\\S+) (?P<status>\\d{3}) (?P<bytes>\\d+)$",
+//	  "labels": ["method", "status"],
+//	  "values": ["bytes"],
+//	  "metrics": [
+//	    {"name": "requests_total", "help": "Counter of requests."},
+//	    {"name": "bytes_total", "help": "Sum of bytes sent.", "value": "bytes"}
+//	  ]
+//	}
+type Config struct {
+	Pattern string `json:"pattern"`
+	// groups used as labels
+	Labels []string `json:"labels"`
+	// groups holding numbers or durations, durations are counted in seconds
+	Values  []string       `json:"values"`
+	Time    *TimeConfig    `json:"time"`
+	Metrics []MetricConfig `json:"metrics"`
+	// label the summary tables are grouped by, default the first label
+	GroupBy string `json:"group_by"`
+}
+
+func LoadConfig(path string) (*Config, error) {
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Config
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("could not parse config %s; %s", path, err)
+	}
+
+	return &c, nil
+}
+
+type regexMetric struct {
+	MetricConfig
+	value  int   // group index of the value, 0 to count lines
+	labels []int // group indexes of the labels
+	by     string
+	vec    *metrics.CounterVec
+}
+
+type RegexFilter struct {
+	re      *regexp.Regexp
+	metrics []regexMetric
+	groupBy string
+
+	timeGroup  int
+	timeLayout string
+
+	lastEventTime atomic.Value
+}
+
+func NewRegexFilter(c *Config) (*RegexFilter, error) {
+
+	re, err := regexp.Compile(c.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %s", err)
+	}
+
+	groups := map[string]int{}
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			groups[name] = i
+		}
+	}
+
+	group := func(name string) (int, error) {
+		i, ok := groups[name]
+		if !ok {
+			return 0, fmt.Errorf("pattern has no group named %s", name)
+		}
+		return i, nil
+	}
+
+	for _, l := range c.Labels {
+		if _, err := group(l); err != nil {
+			return nil, err
+		}
+	}
+
+	values := map[string]bool{}
+	for _, v := range c.Values {
+		if _, err := group(v); err != nil {
+			return nil, err
+		}
+		values[v] = true
+	}
+
+	if len(c.Metrics) == 0 {
+		return nil, fmt.Errorf("no metrics declared")
+	}
+
+	f := &RegexFilter{
+		re:      re,
+		groupBy: c.GroupBy,
+	}
+
+	if f.groupBy == "" && len(c.Labels) > 0 {
+		f.groupBy = c.Labels[0]
+	}
+
+	if c.Time != nil {
+		if f.timeGroup, err = group(c.Time.Group); err != nil {
+			return nil, err
+		}
+		f.timeLayout = c.Time.Layout
+		if f.timeLayout == "" {
+			f.timeLayout = "rfc3339"
+		}
+	}
+
+	for _, mc := range c.Metrics {
+		if mc.Name == "" {
+			return nil, fmt.Errorf("metric without name")
+		}
+
+		m := regexMetric{MetricConfig: mc}
+
+		if mc.Value != "" {
+			if !values[mc.Value] {
+				return nil, fmt.Errorf("value %s of metric %s is not declared in values", mc.Value, mc.Name)
+			}
+			m.value = groups[mc.Value]
+		}
+
+		labels := mc.Labels
+		if len(labels) == 0 {
+			labels = c.Labels
+		}
+		for _, l := range labels {
+			i, err := group(l)
+			if err != nil {
+				return nil, fmt.Errorf("metric %s: %s", mc.Name, err)
+			}
+			m.labels = append(m.labels, i)
+			if l == f.groupBy {
+				m.by = l
+			}
+		}
+
+		m.vec = metrics.NewCounterVec(mc.Name, mc.Help, labels)
+		f.metrics = append(f.metrics, m)
+	}
+
+	return f, nil
+}
+
+func (f *RegexFilter) RegisterMetrics() {
+	for _, m := range f.metrics {
+		metrics.Register(m.vec)
+	}
+}
+
+func (f *RegexFilter) RegisterMonitors() {
+}
+
+func (f *RegexFilter) HandleEntry(time time.Time, entry string) error {
+
+	matches := f.re.FindStringSubmatch(entry)
+	if matches == nil {
+		return filter.ParseErrorf(filter.ReasonFormat, "could not parse line: '%s'", entry)
+	}
+
+	eventTime := time
+	if f.timeGroup > 0 {
+		t, err := filter.ParseTime(f.timeLayout, matches[f.timeGroup])
+		if err != nil {
+			return filter.ParseErrorf(filter.ReasonTime, "could not parse time of line: '%s'; %s", entry, err)
+		}
+		f.lastEventTime.Store(t)
+		eventTime = t
+	}
+
+	// the values are parsed before any metric is updated, so a line
+	// rejected for one of its values is not counted partly
+	values := make([]float64, len(f.metrics))
+	for i, m := range f.metrics {
+		if m.value == 0 {
+			values[i] = 1
+			continue
+		}
+
+		v := matches[m.value]
+		if v == "" || v == "-" {
+			values[i] = -1
+			continue
+		}
+		value, err := filter.ParseValue(v)
+		if err == nil {
+			err = filter.CheckCounterValue(value)
+		}
+		if err != nil {
+			return filter.ParseErrorf(filter.ReasonValue, "could not parse %s of line: '%s'; %s", m.Value, entry, err)
+		}
+		values[i] = value
+	}
+
+	for i, m := range f.metrics {
+
+		// missing value
+		if values[i] < 0 {
+			continue
+		}
+
+		lvs := make([]string, len(m.labels))
+		for j, l := range m.labels {
+			lvs[j] = matches[l]
+		}
+
+		if m.value == 0 {
+			m.vec.WithLabelValues(lvs...).IncAt(eventTime)
+			continue
+		}
+		m.vec.WithLabelValues(lvs...).AddAt(eventTime, values[i])
+	}
+
+	return nil
+}
+
+func (f *RegexFilter) Summary(evalInterval int64) printer.Summary {
+
+	var summary printer.Summary
+
+	graph, ok := filter.RateGraph(f.metrics[0].Name, evalInterval)
+	if !ok {
+		return summary
+	}
+	if t, ok := f.lastEventTime.Load().(time.Time); ok {
+		graph.Title = fmt.Sprintf("%s (last event at %s)", graph.Title, t)
+	}
+	summary.Graphs = append(summary.Graphs, graph)
+
+	for _, m := range f.metrics {
+		title := m.Name + " per second"
+		if m.Help != "" {
+			title = fmt.Sprintf("%s: %s", title, m.Help)
+		}
+
+		if tb, ok := filter.RateTable(title, m.Name, m.by, evalInterval); ok {
+			summary.Tables = append(summary.Tables, tb)
+		}
+	}
+
+	return summary
+}
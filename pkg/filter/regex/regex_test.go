@@ -0,0 +1,96 @@
+package regex
+
+import (
+	"testing"
+	"time"
+
+	"github.com/almariah/ltop/pkg/filter"
+	"github.com/almariah/ltop/pkg/metrics"
+)
+
+func TestHandleEntry(t *testing.T) {
+
+	f, err := NewRegexFilter(&Config{
+		Pattern: `^(?P<time>\S+) (?P<method>\S+) (?P<path>\S+) (?P<status>\d{3}|\S+) (?P<bytes>\S+) (?P<took>\S+)$`,
+		Labels:  []string{"method", "status"},
+		Values:  []string{"bytes", "took"},
+		Time:    &TimeConfig{Group: "time"},
+		Metrics: []MetricConfig{
+			{Name: "requests_total"},
+			{Name: "bytes_total", Value: "bytes", Labels: []string{"method"}},
+			{Name: "duration_seconds_total", Value: "took"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		line   string
+		reason string
+	}{
+		{`2019-05-01T10:00:00Z GET /api 200 512 15ms`, ""},
+		{`2019-05-01T10:00:01Z GET /blog 200 1024 0.5`, ""},
+		{`2019-05-01T10:00:02Z POST /api 500 - -`, ""},
+		{`GET /api 200 512 15ms`, filter.ReasonFormat},
+		{`yesterday GET /api 200 512 15ms`, filter.ReasonTime},
+		{`2019-05-01T10:00:03Z GET /api 200 many 15ms`, filter.ReasonValue},
+		{`2019-05-01T10:00:03Z GET /api 200 -1 15ms`, filter.ReasonValue},
+	}
+
+	for _, test := range tests {
+		err := f.HandleEntry(time.Now(), test.line)
+		if test.reason == "" {
+			if err != nil {
+				t.Errorf("unexpected error for %s: %v", test.line, err)
+			}
+			continue
+		}
+		if err == nil || filter.ErrorReason(err) != test.reason {
+			t.Errorf("expected %s error for %s, got %v", test.reason, test.line, err)
+		}
+	}
+
+	value := func(vec *metrics.CounterVec, lvs ...string) float64 {
+		return vec.WithLabelValues(lvs...).(metrics.Metric).Value()
+	}
+
+	// lines rejected for a value are not counted by any metric
+	expected := []struct {
+		vec   *metrics.CounterVec
+		lvs   []string
+		value float64
+	}{
+		{f.metrics[0].vec, []string{"GET", "200"}, 2},
+		{f.metrics[0].vec, []string{"POST", "500"}, 1},
+		{f.metrics[1].vec, []string{"GET"}, 1536},
+		{f.metrics[1].vec, []string{"POST"}, 0},
+		{f.metrics[2].vec, []string{"GET", "200"}, 0.515},
+	}
+
+	for _, e := range expected {
+		if got := value(e.vec, e.lvs...); got != e.value {
+			t.Errorf("expected %v for %v, got %v", e.value, e.lvs, got)
+		}
+	}
+
+	if got, ok := f.lastEventTime.Load().(time.Time); !ok || !got.Equal(time.Date(2019, 5, 1, 10, 0, 3, 0, time.UTC)) {
+		t.Errorf("unexpected last event time %v", got)
+	}
+}
+
+func TestNewRegexFilterErrors(t *testing.T) {
+
+	for _, c := range []*Config{
+		{Pattern: `(?P<a>\S+`, Metrics: []MetricConfig{{Name: "a_total"}}},
+		{Pattern: `(?P<a>\S+)`, Labels: []string{"b"}, Metrics: []MetricConfig{{Name: "a_total"}}},
+		{Pattern: `(?P<a>\S+)`},
+		{Pattern: `(?P<a>\S+)`, Metrics: []MetricConfig{{Name: "a_total", Value: "a"}}},
+		{Pattern: `(?P<a>\S+)`, Time: &TimeConfig{Group: "time"}, Metrics: []MetricConfig{{Name: "a_total"}}},
+		{Pattern: `(?P<a>\S+)`, Metrics: []MetricConfig{{}}},
+	} {
+		if _, err := NewRegexFilter(c); err == nil {
+			t.Errorf("expected error for config %+v", c)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package filter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Option describes a single configuration option accepted by a filter.
+type Option struct {
+	Name    string
+	Usage   string
+	Default string
+}
+
+// Options holds the option values passed to a filter constructor. Options
+// not set by the user hold their declared default.
+type Options map[string]string
+
+func (o Options) String(name string) string {
+	return o[name]
+}
+
+func (o Options) Int(name string) (int, error) {
+	v := o[name]
+	if v == "" {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value for option %s: %s", name, v)
+	}
+	return i, nil
+}
+
+func (o Options) Float64(name string) (float64, error) {
+	v := o[name]
+	if v == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value for option %s: %s", name, v)
+	}
+	return f, nil
+}
+
+func (o Options) Bool(name string) (bool, error) {
+	v := o[name]
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid value for option %s: %s", name, v)
+	}
+	return b, nil
+}
+
+func (o Options) Duration(name string) (time.Duration, error) {
+	v := o[name]
+	if v == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value for option %s: %s", name, v)
+	}
+	return d, nil
+}
+
+// List returns the comma separated values of the option.
+func (o Options) List(name string) []string {
+	var result []string
+	for _, v := range strings.Split(o[name], ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// Constructor creates a new filter from the given options.
+type Constructor func(opts Options) (Filter, error)
+
+// Registration holds everything needed to list and construct a filter.
+type Registration struct {
+	Name        string
+	Description string
+	Options     []Option
+	New         Constructor
+}
+
+var (
+	registryMtx sync.RWMutex
+	registry    = map[string]Registration{}
+)
+
+// Register makes a filter available by name. It is meant to be called from
+// the init function of the package implementing the filter, and panics if a
+// filter with the same name is already registered.
+func Register(r Registration) {
+	registryMtx.Lock()
+	defer registryMtx.Unlock()
+
+	if r.New == nil {
+		panic(fmt.Sprintf("filter %s registered without constructor", r.Name))
+	}
+	if _, exists := registry[r.Name]; exists {
+		panic(fmt.Sprintf("filter %s already registered", r.Name))
+	}
+	registry[r.Name] = r
+}
+
+// Registered returns all registered filters sorted by name.
+func Registered() []Registration {
+	registryMtx.RLock()
+	defer registryMtx.RUnlock()
+
+	var result []Registration
+	for _, r := range registry {
+		result = append(result, r)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// New constructs the filter registered under name. Every key of opts has to
+// be declared by the filter, missing options are set to their default.
+func New(name string, opts map[string]string) (Filter, error) {
+	registryMtx.RLock()
+	r, ok := registry[name]
+	registryMtx.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("invalid filter name; %s", name)
+	}
+
+	values := Options{}
+	for _, o := range r.Options {
+		values[o.Name] = o.Default
+	}
+	for k, v := range opts {
+		if _, declared := values[k]; !declared {
+			return nil, fmt.Errorf("filter %s has no option %s", name, k)
+		}
+		values[k] = v
+	}
+
+	return r.New(values)
+}
+
+// ParseOptions parses options given in key=value form.
+func ParseOptions(kvs []string) (map[string]string, error) {
+	opts := map[string]string{}
+	for _, kv := range kvs {
+		i := strings.Index(kv, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid filter option %q; expected key=value", kv)
+		}
+		opts[kv[:i]] = kv[i+1:]
+	}
+	return opts, nil
+}
@@ -0,0 +1,123 @@
+package filter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/almariah/ltop/pkg/printer"
+)
+
+// optionsFilter is a stub filter keeping the options it was created with.
+type optionsFilter struct {
+	opts Options
+}
+
+func (f *optionsFilter) HandleEntry(time.Time, string) error { return nil }
+func (f *optionsFilter) Summary(int64) printer.Summary       { return printer.Summary{} }
+func (f *optionsFilter) RegisterMetrics()                    {}
+func (f *optionsFilter) RegisterMonitors()                   {}
+
+func TestRegistry(t *testing.T) {
+
+	Register(Registration{
+		Name: "test-options",
+		Options: []Option{
+			{Name: "format", Default: "common"},
+			{Name: "labels"},
+		},
+		New: func(opts Options) (Filter, error) {
+			return &optionsFilter{opts: opts}, nil
+		},
+	})
+
+	f, err := New("test-options", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts := f.(*optionsFilter).opts; !reflect.DeepEqual(opts, Options{"format": "common", "labels": ""}) {
+		t.Fatalf("unexpected default options %v", opts)
+	}
+
+	f, err = New("test-options", map[string]string{"labels": "a,b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts := f.(*optionsFilter).opts; opts.String("format") != "common" || !reflect.DeepEqual(opts.List("labels"), []string{"a", "b"}) {
+		t.Fatalf("unexpected options %v", opts)
+	}
+
+	if _, err := New("test-options", map[string]string{"color": "red"}); err == nil {
+		t.Fatal("expected an error for an unknown option")
+	}
+	if _, err := New("test-unknown", nil); err == nil {
+		t.Fatal("expected an error for an unknown filter")
+	}
+
+	found := false
+	for _, r := range Registered() {
+		found = found || r.Name == "test-options"
+	}
+	if !found {
+		t.Fatal("expected the registered filter to be listed")
+	}
+
+	for _, r := range []Registration{
+		{Name: "test-options", New: func(Options) (Filter, error) { return &optionsFilter{}, nil }},
+		{Name: "test-no-constructor"},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected registration of %s to panic", r.Name)
+				}
+			}()
+			Register(r)
+		}()
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+
+	opts, err := ParseOptions([]string{"format=%h %l", "labels=a=b,c", "empty="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := map[string]string{"format": "%h %l", "labels": "a=b,c", "empty": ""}
+	if !reflect.DeepEqual(opts, exp) {
+		t.Fatalf("unexpected options %v", opts)
+	}
+
+	for _, kv := range []string{"format", "=value"} {
+		if _, err := ParseOptions([]string{kv}); err == nil {
+			t.Errorf("expected an error for %q", kv)
+		}
+	}
+}
+
+func TestOptionsValues(t *testing.T) {
+
+	opts := Options{"n": "3", "f": "0.5", "b": "true", "d": "2s", "bad": "x"}
+
+	if n, err := opts.Int("n"); err != nil || n != 3 {
+		t.Errorf("unexpected int %d %v", n, err)
+	}
+	if f, err := opts.Float64("f"); err != nil || f != 0.5 {
+		t.Errorf("unexpected float %f %v", f, err)
+	}
+	if b, err := opts.Bool("b"); err != nil || !b {
+		t.Errorf("unexpected bool %v %v", b, err)
+	}
+	if d, err := opts.Duration("d"); err != nil || d.Seconds() != 2 {
+		t.Errorf("unexpected duration %v %v", d, err)
+	}
+	if n, err := opts.Int("missing"); err != nil || n != 0 {
+		t.Errorf("expected zero for a missing option, got %d %v", n, err)
+	}
+	if _, err := opts.Int("bad"); err == nil {
+		t.Error("expected an error for an invalid int")
+	}
+	if _, err := opts.Duration("bad"); err == nil {
+		t.Error("expected an error for an invalid duration")
+	}
+}
@@ -0,0 +1,323 @@
+package filter
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/almariah/ltop/pkg/metrics"
+	"github.com/almariah/ltop/pkg/printer"
+)
+
+// FieldCounter is a counter summing a numeric field of structured entries.
+type FieldCounter struct {
+	Mapping
+	Vec *metrics.CounterVec
+}
+
+// FieldSummary is a quantile summary observing a numeric field of
+// structured entries.
+type FieldSummary struct {
+	Mapping
+	Vec *metrics.QuantileSummaryVec
+}
+
+// the quantiles estimated by the summaries of observed fields
+var fieldObjectives = map[float64]float64{0.5: 0.05, 0.95: 0.01, 0.99: 0.001}
+
+// ObserveOptions are the options of the filters of structured logs to
+// observe numeric fields into quantile summaries.
+var ObserveOptions = []Option{
+	{
+		Name:  "observe",
+		Usage: "comma separated metric=field mappings of numeric fields observed into quantile summaries of the last 10 minutes, durations like 12ms are observed in seconds",
+	},
+}
+
+// InFlightOptions are the options of the filters of structured logs to
+// derive the number of requests in flight from pairs of entries logged when
+// requests start and end.
+var InFlightOptions = []Option{
+	{
+		Name:  "in-flight-field",
+		Usage: "field marking the start and the end of requests, counted in the requests_in_flight gauge",
+	},
+	{
+		Name:  "in-flight-start",
+		Usage: "value of in-flight-field of entries logged when requests start",
+	},
+	{
+		Name:  "in-flight-end",
+		Usage: "value of in-flight-field of entries logged when requests end",
+	},
+}
+
+// Structured counts entries of structured logs, e.g. JSON or logfmt, broken
+// out by labels taken from their fields, sums numeric fields into counters
+// and observes them into quantile summaries. It is embedded by the filters of structured logs which only
+// decode the entries.
+type Structured struct {
+	Labels   []Mapping
+	Counters []FieldCounter
+	Observed []FieldSummary
+
+	TimeField  string
+	TimeLayout string
+	GroupBy    string
+
+	EntryCounter *metrics.CounterVec
+
+	// requests in flight, nil unless in-flight-field is set
+	InFlightField string
+	inFlightStart string
+	inFlightEnd   string
+	InFlight      metrics.Gauge
+	inFlightMtx   sync.Mutex
+	inFlight      int
+
+	lastEventTime atomic.Value
+}
+
+// NewStructured reads the labels, time-field, time-layout, group-by,
+// observe and in-flight options and the given option of the counter mappings.
+func NewStructured(opts Options, counters string) (*Structured, error) {
+
+	s := &Structured{
+		Labels:     ParseMappings(opts.List("labels")),
+		TimeField:  opts.String("time-field"),
+		TimeLayout: opts.String("time-layout"),
+		GroupBy:    opts.String("group-by"),
+	}
+
+	var labelNames []string
+	for _, l := range s.Labels {
+		labelNames = append(labelNames, l.Name)
+	}
+
+	if s.GroupBy == "" && len(labelNames) > 0 {
+		s.GroupBy = labelNames[0]
+	}
+
+	s.EntryCounter = metrics.NewCounterVec(
+		"log_entries_total",
+		"Counter of log entries broken out by the configured labels.",
+		labelNames,
+	)
+
+	for _, m := range ParseMappings(opts.List(counters)) {
+		if !strings.HasSuffix(m.Name, "_total") {
+			m.Name += "_total"
+		}
+		s.Counters = append(s.Counters, FieldCounter{
+			Mapping: m,
+			Vec: metrics.NewCounterVec(
+				m.Name,
+				fmt.Sprintf("Sum of the field %s broken out by the configured labels.", m.Field),
+				labelNames,
+			),
+		})
+	}
+
+	for _, m := range ParseMappings(opts.List("observe")) {
+		s.Observed = append(s.Observed, FieldSummary{
+			Mapping: m,
+			Vec: metrics.NewQuantileSummaryVec(metrics.QuantileSummaryOpts{
+				Name:       m.Name,
+				Help:       fmt.Sprintf("Quantiles of the field %s of the last 10 minutes broken out by the configured labels.", m.Field),
+				Objectives: fieldObjectives,
+			}, labelNames),
+		})
+	}
+
+	s.InFlightField = opts.String("in-flight-field")
+	if s.InFlightField != "" {
+		s.inFlightStart = opts.String("in-flight-start")
+		s.inFlightEnd = opts.String("in-flight-end")
+		if s.inFlightStart == "" || s.inFlightEnd == "" || s.inFlightStart == s.inFlightEnd {
+			return nil, fmt.Errorf("in-flight-field requires distinct in-flight-start and in-flight-end values")
+		}
+		s.InFlight = metrics.NewGauge(
+			"requests_in_flight",
+			fmt.Sprintf("Number of requests started but not ended according to the field %s.", s.InFlightField),
+		)
+	}
+
+	return s, nil
+}
+
+func (s *Structured) RegisterMetrics() {
+	metrics.Register(s.EntryCounter)
+	for _, c := range s.Counters {
+		metrics.Register(c.Vec)
+	}
+	for _, o := range s.Observed {
+		metrics.Register(o.Vec)
+	}
+	if s.InFlight != nil {
+		metrics.Register(s.InFlight)
+	}
+}
+
+func (s *Structured) RegisterMonitors() {
+}
+
+// HandleFields counts an entry whose fields are returned by lookup.
+func (s *Structured) HandleFields(time time.Time, entry string, lookup func(field string) (string, bool)) error {
+
+	eventTime := time
+	if s.TimeField != "" {
+		v, ok := lookup(s.TimeField)
+		if !ok {
+			return ParseErrorf(ReasonMissingField, "missing time field %s in line: '%s'", s.TimeField, entry)
+		}
+		t, err := ParseTime(s.TimeLayout, v)
+		if err != nil {
+			return ParseErrorf(ReasonTime, "could not parse time of line: '%s'; %s", entry, err)
+		}
+		s.lastEventTime.Store(t)
+		eventTime = t
+	}
+
+	lvs := make([]string, len(s.Labels))
+	for i, l := range s.Labels {
+		lvs[i], _ = lookup(l.Field)
+	}
+
+	// the fields are parsed before any metric is updated, so a line
+	// rejected for one of its fields is not counted partly
+	values := make([]float64, len(s.Counters))
+	for i, c := range s.Counters {
+		v, ok := lookup(c.Field)
+		if !ok {
+			values[i] = -1
+			continue
+		}
+		value, err := ParseValue(v)
+		if err == nil {
+			err = CheckCounterValue(value)
+		}
+		if err != nil {
+			return ParseErrorf(ReasonValue, "could not parse %s of line: '%s'; %s", c.Field, entry, err)
+		}
+		values[i] = value
+	}
+
+	observations := make([]float64, len(s.Observed))
+	observed := make([]bool, len(s.Observed))
+	for i, o := range s.Observed {
+		v, ok := lookup(o.Field)
+		if !ok {
+			continue
+		}
+		value, err := ParseValue(v)
+		if err == nil && (math.IsNaN(value) || math.IsInf(value, 0)) {
+			err = fmt.Errorf("invalid observation %v", value)
+		}
+		if err != nil {
+			return ParseErrorf(ReasonValue, "could not parse %s of line: '%s'; %s", o.Field, entry, err)
+		}
+		observations[i], observed[i] = value, true
+	}
+
+	for i, c := range s.Counters {
+		// missing field
+		if values[i] < 0 {
+			continue
+		}
+		c.Vec.WithLabelValues(lvs...).AddAt(eventTime, values[i])
+	}
+
+	for i, o := range s.Observed {
+		if observed[i] {
+			o.Vec.WithLabelValues(lvs...).ObserveAt(eventTime, observations[i])
+		}
+	}
+
+	s.EntryCounter.WithLabelValues(lvs...).IncAt(eventTime)
+
+	if s.InFlight != nil {
+		if v, ok := lookup(s.InFlightField); ok {
+			s.trackInFlight(v)
+		}
+	}
+
+	return nil
+}
+
+// trackInFlight updates the requests in flight. Ends of requests started
+// before the log was read are ignored so the gauge does not go negative.
+func (s *Structured) trackInFlight(v string) {
+	s.inFlightMtx.Lock()
+	defer s.inFlightMtx.Unlock()
+
+	switch v {
+	case s.inFlightStart:
+		s.inFlight++
+	case s.inFlightEnd:
+		if s.inFlight == 0 {
+			return
+		}
+		s.inFlight--
+	default:
+		return
+	}
+	s.InFlight.Set(float64(s.inFlight))
+}
+
+// LastEventTime returns the time of the last entry with a time field.
+func (s *Structured) LastEventTime() (time.Time, bool) {
+	t, ok := s.lastEventTime.Load().(time.Time)
+	return t, ok
+}
+
+func (s *Structured) tableTitle(name string) string {
+	if s.GroupBy == "" {
+		return name + " per second"
+	}
+	return name + " per second grouped by " + s.GroupBy
+}
+
+func (s *Structured) Summary(evalInterval int64) printer.Summary {
+
+	var summary printer.Summary
+
+	graph, ok := RateGraph("log_entries_total", evalInterval)
+	if !ok {
+		return summary
+	}
+	if t, ok := s.LastEventTime(); ok {
+		graph.Title = fmt.Sprintf("%s (last event at %s)", graph.Title, t)
+	}
+	summary.Graphs = append(summary.Graphs, graph)
+
+	if tb, ok := RateTable(s.tableTitle("entries"), "log_entries_total", s.GroupBy, evalInterval); ok {
+		summary.Tables = append(summary.Tables, tb)
+	}
+
+	for _, c := range s.Counters {
+		if tb, ok := RateTable(s.tableTitle(c.Name), c.Name, s.GroupBy, evalInterval); ok {
+			summary.Tables = append(summary.Tables, tb)
+		}
+	}
+
+	for _, o := range s.Observed {
+		title := o.Name
+		if s.GroupBy != "" {
+			title += " grouped by " + s.GroupBy
+		}
+		if tb, ok := QuantileTable(title, o.Name, s.GroupBy, evalInterval); ok {
+			summary.Tables = append(summary.Tables, tb)
+		}
+	}
+
+	if s.InFlight != nil {
+		if tb, ok := GaugeTable("requests in flight", "requests_in_flight", "", evalInterval); ok {
+			summary.Tables = append(summary.Tables, tb)
+		}
+	}
+
+	return summary
+}
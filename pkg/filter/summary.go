@@ -0,0 +1,317 @@
+package filter
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/almariah/ltop/pkg/metrics"
+	"github.com/almariah/ltop/pkg/printer"
+)
+
+// number of evaluation intervals covered by summaries
+const EvalIntervalNumber = 60
+
+// RateTable returns the current rate per second of the counter with the given
+// name, in total and grouped by the given label. It returns false if nothing
+// was collected yet.
+func RateTable(title string, name string, by string, evalInterval int64) (printer.Table, bool) {
+
+	column := by
+	if column == "" {
+		column = "labels"
+	}
+
+	tb := printer.Table{
+		Title:  title,
+		Header: []string{column, "rate (per second)"},
+	}
+
+	last := EvalIntervalNumber * evalInterval
+
+	m := metrics.QueryLast(name, []metrics.Label{}, last, evalInterval)
+	if len(m) == 0 {
+		return tb, false
+	}
+
+	totalRate := metrics.Rate(metrics.Sum(m))
+	tb.Data = append(tb.Data, []string{"*", fmt.Sprintf("%f", totalRate.Points[len(totalRate.Points)-1])})
+
+	if by == "" {
+		return tb, true
+	}
+
+	for _, s := range metrics.SumBy(m, []string{by}) {
+		r := metrics.Rate(s)
+		value := "-"
+		if len(r.Metric) > 0 {
+			value = r.Metric[0].Value
+		}
+		tb.Data = append(tb.Data, []string{value, fmt.Sprintf("%f", r.Points[len(r.Points)-1])})
+	}
+
+	return tb, true
+}
+
+// RateGraph returns a graph of the total rate per second of the counter with
+// the given name. It returns false if nothing was collected yet.
+func RateGraph(name string, evalInterval int64) (printer.Graph, bool) {
+
+	last := EvalIntervalNumber * evalInterval
+
+	m := metrics.QueryLast(name, []metrics.Label{}, last, evalInterval)
+	if len(m) == 0 {
+		return printer.Graph{}, false
+	}
+
+	totalRate := metrics.Rate(metrics.Sum(m))
+
+	return printer.Graph{
+		Title: fmt.Sprintf("rate of %s for last %d seconds over %d seconds interval", name, last, evalInterval),
+		Data:  totalRate.Points,
+	}, true
+}
+
+// GaugeTable returns the current value of the gauge with the given name,
+// summed by the given label. It returns false if nothing was collected yet.
+func GaugeTable(title string, name string, by string, evalInterval int64) (printer.Table, bool) {
+
+	tb := printer.Table{
+		Title:  title,
+		Header: []string{by, "value"},
+	}
+
+	m := metrics.QueryLast(name, []metrics.Label{}, evalInterval, evalInterval)
+	if len(m) == 0 {
+		return tb, false
+	}
+
+	for _, s := range metrics.SumBy(m, []string{by}) {
+		if len(s.Points) == 0 {
+			continue
+		}
+		value := "-"
+		if len(s.Metric) > 0 {
+			value = s.Metric[0].Value
+		}
+		tb.Data = append(tb.Data, []string{value, fmt.Sprintf("%f", s.Points[len(s.Points)-1])})
+	}
+
+	return tb, true
+}
+
+// HistogramTable returns the distribution of the observations of the
+// histogram with the given name over the summarized range, one row per
+// bucket. It returns false if nothing was collected yet.
+func HistogramTable(title string, name string, evalInterval int64) (printer.Table, bool) {
+
+	tb := printer.Table{
+		Title:  title,
+		Header: []string{"bucket", "observations", "share"},
+	}
+
+	last := EvalIntervalNumber * evalInterval
+
+	m := metrics.QueryLast(name+"_bucket", []metrics.Label{}, last, evalInterval)
+	if len(m) == 0 {
+		return tb, false
+	}
+
+	type bucket struct {
+		le       string
+		bound    float64
+		increase float64
+	}
+
+	var buckets []bucket
+	for _, s := range metrics.SumBy(m, []string{"le"}) {
+		if len(s.Metric) == 0 || len(s.Points) == 0 {
+			continue
+		}
+		bound, err := strconv.ParseFloat(s.Metric[0].Value, 64)
+		if err != nil {
+			continue
+		}
+		buckets = append(buckets, bucket{
+			le:       s.Metric[0].Value,
+			bound:    bound,
+			increase: s.Points[len(s.Points)-1] - s.Points[0],
+		})
+	}
+	if len(buckets) == 0 {
+		return tb, false
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].bound < buckets[j].bound })
+
+	// the +Inf bucket counts all observations
+	total := buckets[len(buckets)-1].increase
+
+	lower, prev := "0", 0.0
+	for _, b := range buckets {
+		n := b.increase - prev
+		share := 0.0
+		if total > 0 {
+			share = 100 * n / total
+		}
+		label := fmt.Sprintf("(%s, %s]", lower, b.le)
+		if math.IsInf(b.bound, 1) {
+			label = fmt.Sprintf("> %s", lower)
+		}
+		tb.Data = append(tb.Data, []string{label, fmt.Sprintf("%.0f", n), fmt.Sprintf("%.1f%%", share)})
+		lower, prev = b.le, b.increase
+	}
+
+	return tb, true
+}
+
+// TopTable returns the n most frequent keys of the tracker with their
+// estimated count and its error bound. It returns false if nothing was
+// counted in the window.
+func TopTable(title string, column string, tk *metrics.TopK, n int) (printer.Table, bool) {
+
+	tb := printer.Table{
+		Title:  title,
+		Header: []string{column, "count (estimated)", "max overcount"},
+	}
+
+	top := tk.Top(n)
+	if len(top) == 0 {
+		return tb, false
+	}
+
+	for _, e := range top {
+		tb.Data = append(tb.Data, []string{e.Key, fmt.Sprintf("%.0f", e.Count), fmt.Sprintf("%.0f", e.Error)})
+	}
+
+	return tb, true
+}
+
+// CardinalityTable returns the number of distinct values of the last range,
+// in total and grouped by the given label. It returns false if nothing was
+// added in the range.
+func CardinalityTable(title string, by string, vec *metrics.CardinalityVec, last time.Duration) (printer.Table, bool) {
+
+	tb := printer.Table{
+		Title:  title,
+		Header: []string{by, "distinct (estimated)"},
+	}
+
+	groups := vec.EstimateBy(by, last)
+	if len(groups) == 0 {
+		return tb, false
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Value > groups[j].Value })
+
+	tb.Data = append(tb.Data, []string{"*", fmt.Sprintf("%.0f", vec.Estimate(last))})
+	for _, g := range groups {
+		tb.Data = append(tb.Data, []string{g.Labels[0].Value, fmt.Sprintf("%.0f", g.Value)})
+	}
+
+	return tb, true
+}
+
+// QuantileTable returns the current quantiles of the quantile summary with
+// the given name, one row per value of the given label and one column per
+// quantile. The summary has a single row "*" if by is empty. It returns false
+// if nothing was collected yet.
+func QuantileTable(title string, name string, by string, evalInterval int64) (printer.Table, bool) {
+
+	column := by
+	if column == "" {
+		column = "labels"
+	}
+
+	tb := printer.Table{
+		Title:  title,
+		Header: []string{column},
+	}
+
+	m := metrics.QueryLast(name, []metrics.Label{}, evalInterval, evalInterval)
+	if len(m) == 0 {
+		return tb, false
+	}
+
+	// current value by row and quantile
+	values := map[string]map[float64]float64{}
+	var quantiles []float64
+	seen := map[float64]bool{}
+
+	for _, s := range m {
+		if len(s.Points) == 0 {
+			continue
+		}
+		row, quantile := "*", ""
+		for _, l := range s.Metric {
+			switch l.Name {
+			case "quantile":
+				quantile = l.Value
+			case by:
+				row = l.Value
+			}
+		}
+		q, err := strconv.ParseFloat(quantile, 64)
+		if err != nil {
+			continue
+		}
+		if !seen[q] {
+			seen[q] = true
+			quantiles = append(quantiles, q)
+		}
+		if values[row] == nil {
+			values[row] = map[float64]float64{}
+		}
+		values[row][q] = s.Points[len(s.Points)-1]
+	}
+	if len(values) == 0 {
+		return tb, false
+	}
+	sort.Float64s(quantiles)
+
+	for _, q := range quantiles {
+		tb.Header = append(tb.Header, fmt.Sprintf("p%g", 100*q))
+	}
+
+	rows := make([]string, 0, len(values))
+	for row := range values {
+		rows = append(rows, row)
+	}
+	sort.Strings(rows)
+
+	for _, row := range rows {
+		data := []string{row}
+		for _, q := range quantiles {
+			value := "-"
+			if v, ok := values[row][q]; ok {
+				value = fmt.Sprintf("%f", v)
+			}
+			data = append(data, value)
+		}
+		tb.Data = append(tb.Data, data)
+	}
+
+	return tb, true
+}
+
+// QuantileGraph returns a graph of the quantile q of the unlabeled quantile
+// summary with the given name. It returns false if nothing was collected
+// yet.
+func QuantileGraph(name string, q float64, evalInterval int64) (printer.Graph, bool) {
+
+	last := EvalIntervalNumber * evalInterval
+
+	quantile := strconv.FormatFloat(q, 'g', -1, 64)
+	for _, s := range metrics.QueryLast(name, []metrics.Label{}, last, evalInterval) {
+		for _, l := range s.Metric {
+			if l.Name == "quantile" && l.Value == quantile && len(s.Points) > 0 {
+				return printer.Graph{
+					Title: fmt.Sprintf("p%g of %s for last %d seconds over %d seconds interval", 100*q, name, last, evalInterval),
+					Data:  s.Points,
+				}, true
+			}
+		}
+	}
+
+	return printer.Graph{}, false
+}
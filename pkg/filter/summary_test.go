@@ -0,0 +1,67 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/almariah/ltop/pkg/metrics"
+)
+
+func TestQuantileTable(t *testing.T) {
+
+	opts := metrics.QuantileSummaryOpts{
+		Name:       "test_duration_seconds",
+		Objectives: map[float64]float64{0.5: 0.01, 0.99: 0.001},
+	}
+	total := metrics.NewQuantileSummary(opts)
+	opts.Name = "test_section_duration_seconds"
+	bySection := metrics.NewQuantileSummaryVec(opts, []string{"section"})
+	metrics.Register(total, bySection)
+
+	if _, ok := QuantileTable("durations", "test_duration_seconds", "", 10); ok {
+		t.Fatal("expected no table before the first collection")
+	}
+
+	for i := 1; i <= 100; i++ {
+		total.Observe(float64(i))
+		bySection.WithLabelValues("/api").Observe(float64(i))
+		bySection.WithLabelValues("/blog").Observe(float64(2 * i))
+	}
+	metrics.SetCollectInterval(10)
+	metrics.CollectUntil(metrics.Now())
+
+	tests := []struct {
+		name string
+		by   string
+		rows []string
+	}{
+		{"test_duration_seconds", "", []string{"labels | p50 | p99", "* | 50.000000 | 99.000000"}},
+		{"test_section_duration_seconds", "section", []string{"section | p50 | p99", "/api | 50.000000 | 99.000000", "/blog | 100.000000 | 198.000000"}},
+	}
+
+	for _, test := range tests {
+		tb, ok := QuantileTable("durations", test.name, test.by, 10)
+		if !ok {
+			t.Fatalf("expected a table of %s", test.name)
+		}
+		rows := []string{strings.Join(tb.Header, " | ")}
+		for _, d := range tb.Data {
+			rows = append(rows, strings.Join(d, " | "))
+		}
+		if strings.Join(rows, "\n") != strings.Join(test.rows, "\n") {
+			t.Errorf("unexpected table of %s:\n%s", test.name, strings.Join(rows, "\n"))
+		}
+	}
+
+	g, ok := QuantileGraph("test_duration_seconds", 0.99, 10)
+	if !ok {
+		t.Fatal("expected a graph of the p99")
+	}
+	if !strings.HasPrefix(g.Title, "p99 of test_duration_seconds") || len(g.Data) == 0 || g.Data[len(g.Data)-1] != 99 {
+		t.Fatalf("unexpected graph %q %v", g.Title, g.Data)
+	}
+
+	if _, ok := QuantileGraph("test_duration_seconds", 0.95, 10); ok {
+		t.Fatal("expected no graph of an unknown quantile")
+	}
+}
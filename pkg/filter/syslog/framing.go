@@ -0,0 +1,94 @@
+package syslog
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/almariah/ltop/pkg/filter"
+)
+
+// frameLength returns the length of the octet-counted frame (RFC 6587) at
+// the start of s and the offset of its message. Only frames of messages with
+// PRI are recognized, so that RFC 5424 messages written without PRI, which
+// start with the version, are not taken for frames.
+func frameLength(s string) (int, int, bool) {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	if i == 0 || i > 9 || s[0] == '0' || i+1 >= len(s) || s[i] != ' ' || s[i+1] != '<' {
+		return 0, 0, false
+	}
+	n, err := strconv.Atoi(s[:i])
+	if err != nil {
+		return 0, 0, false
+	}
+	return n, i + 1, true
+}
+
+// deframer splits lines of octet-counted frames into messages, lines which
+// are not framed are returned as they are. Several frames may be sent on a
+// single line, and since messages may contain line breaks a frame may
+// continue on the following lines, in which case its message is buffered.
+type deframer struct {
+	// message of the frame continued on the next line and the number of
+	// octets still missing
+	pending   strings.Builder
+	remaining int
+}
+
+// split returns the complete messages of the line.
+func (d *deframer) split(line string) ([]string, error) {
+
+	var messages []string
+
+	if d.remaining > 0 {
+		if len(line) < d.remaining {
+			// the line break is part of the message
+			d.pending.WriteString(line)
+			d.pending.WriteByte('\n')
+			d.remaining -= len(line) + 1
+			if d.remaining == 0 {
+				messages = append(messages, strings.TrimSuffix(d.flush(), "\n"))
+			}
+			return messages, nil
+		}
+		d.pending.WriteString(line[:d.remaining])
+		line = line[d.remaining:]
+		messages = append(messages, d.flush())
+	} else if _, _, ok := frameLength(line); !ok {
+		return []string{line}, nil
+	}
+
+	for line = strings.TrimLeft(line, " "); line != ""; line = strings.TrimLeft(line, " ") {
+		n, offset, ok := frameLength(line)
+		if !ok {
+			return messages, filter.ParseErrorf(filter.ReasonFormat, "invalid octet-counted frame: '%s'", line)
+		}
+		line = line[offset:]
+
+		if n > len(line)+1 {
+			d.pending.WriteString(line)
+			d.pending.WriteByte('\n')
+			d.remaining = n - len(line) - 1
+			break
+		}
+		if n == len(line)+1 {
+			// the message ends with the line break
+			messages = append(messages, line)
+			break
+		}
+
+		messages = append(messages, line[:n])
+		line = line[n:]
+	}
+
+	return messages, nil
+}
+
+func (d *deframer) flush() string {
+	s := d.pending.String()
+	d.pending.Reset()
+	d.remaining = 0
+	return s
+}
@@ -0,0 +1,63 @@
+package syslog
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeframer(t *testing.T) {
+
+	tests := []struct {
+		lines    []string
+		messages []string
+		errors   int
+	}{
+		{
+			// not framed
+			lines:    []string{"<34>1 2003-10-11T22:14:15.003Z host su - ID47 - hello", "1 2003-10-11T22:14:15.003Z host su - - - hello"},
+			messages: []string{"<34>1 2003-10-11T22:14:15.003Z host su - ID47 - hello", "1 2003-10-11T22:14:15.003Z host su - - - hello"},
+		},
+		{
+			// several frames on a line
+			lines:    []string{"11 <34>1 hello6 <13>hi"},
+			messages: []string{"<34>1 hello", "<13>hi"},
+		},
+		{
+			// frames separated by spaces
+			lines:    []string{"6 <13>hi 6 <13>ho"},
+			messages: []string{"<13>hi", "<13>ho"},
+		},
+		{
+			// a frame continued on the following lines
+			lines:    []string{"22 <13>first", "second", "third6 <13>hi"},
+			messages: []string{"<13>first\nsecond\nthird", "<13>hi"},
+		},
+		{
+			// the line break ends the message
+			lines:    []string{"7 <13>hi", "6 <13>ho"},
+			messages: []string{"<13>hi", "<13>ho"},
+		},
+		{
+			// garbage after a frame
+			lines:    []string{"6 <13>hi garbage"},
+			messages: []string{"<13>hi"},
+			errors:   1,
+		},
+	}
+
+	for _, test := range tests {
+		var d deframer
+		var messages []string
+		errors := 0
+		for _, line := range test.lines {
+			m, err := d.split(line)
+			if err != nil {
+				errors++
+			}
+			messages = append(messages, m...)
+		}
+		if !reflect.DeepEqual(messages, test.messages) || errors != test.errors {
+			t.Errorf("lines %q: expected %q and %d errors, got %q and %d errors", test.lines, test.messages, test.errors, messages, errors)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package syslog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/almariah/ltop/pkg/filter"
+)
+
+var facilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var severities = []string{
+	"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug",
+}
+
+const unknown = "unknown"
+
+// Message is a syslog message as defined by RFC 3164 or RFC 5424. Fields
+// which are not present in the message hold "-".
+type Message struct {
+	Facility       string
+	Severity       string
+	Version        int
+	Time           time.Time
+	Hostname       string
+	AppName        string
+	ProcID         string
+	MsgID          string
+	StructuredData map[string]map[string]string
+	Message        string
+}
+
+// Parse parses a syslog message in RFC 5424 or RFC 3164 format. The PRI
+// part may be missing as in files written by syslog daemons, in which case
+// facility and severity are unknown. RFC 3164 timestamps have no year, the
+// year is taken from now.
+func Parse(line string, now time.Time) (*Message, error) {
+
+	m := &Message{
+		Facility: unknown,
+		Severity: unknown,
+		Hostname: "-",
+		AppName:  "-",
+		ProcID:   "-",
+		MsgID:    "-",
+	}
+
+	rest := line
+
+	if strings.HasPrefix(rest, "<") {
+		end := strings.IndexByte(rest, '>')
+		if end < 2 || end > 4 {
+			return nil, filter.ParseErrorf(filter.ReasonFormat, "invalid PRI of line: '%s'", line)
+		}
+		pri, err := strconv.Atoi(rest[1:end])
+		if err != nil || pri > 191 {
+			return nil, filter.ParseErrorf(filter.ReasonFormat, "invalid PRI of line: '%s'", line)
+		}
+		m.Facility = facilities[pri/8]
+		m.Severity = severities[pri%8]
+		rest = rest[end+1:]
+
+		if len(rest) > 1 && rest[0] >= '1' && rest[0] <= '9' && rest[1] == ' ' {
+			m.Version = int(rest[0] - '0')
+			if err := m.parseRFC5424(rest[2:]); err != nil {
+				return nil, parseError(line, err)
+			}
+			return m, nil
+		}
+	}
+
+	if err := m.parseRFC3164(rest, now); err != nil {
+		return nil, parseError(line, err)
+	}
+
+	return m, nil
+}
+
+// nextField returns the next space separated field and the rest of s.
+func nextField(s string) (string, string) {
+	s = strings.TrimLeft(s, " ")
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+func (m *Message) parseRFC5424(s string) error {
+
+	var ts string
+	ts, s = nextField(s)
+	if ts != "-" {
+		t, err := time.Parse(time.RFC3339Nano, ts)
+		if err != nil {
+			return filter.ParseErrorf(filter.ReasonTime, "invalid timestamp %q", ts)
+		}
+		m.Time = t
+	}
+
+	m.Hostname, s = nextField(s)
+	m.AppName, s = nextField(s)
+	m.ProcID, s = nextField(s)
+	m.MsgID, s = nextField(s)
+
+	if m.MsgID == "" {
+		return fmt.Errorf("missing header fields")
+	}
+
+	s = strings.TrimLeft(s, " ")
+	if strings.HasPrefix(s, "-") {
+		s = s[1:]
+	} else if strings.HasPrefix(s, "[") {
+		sd, rest, err := parseStructuredData(s)
+		if err != nil {
+			return err
+		}
+		m.StructuredData = sd
+		s = rest
+	} else if s != "" {
+		return fmt.Errorf("invalid structured data")
+	}
+
+	m.Message = strings.TrimPrefix(strings.TrimPrefix(s, " "), "\ufeff")
+
+	return nil
+}
+
+// parseStructuredData parses the SD-ELEMENTs at the start of s, e.g.
+// [exampleSDID@32473 iut="3" eventSource="Application"].
+func parseStructuredData(s string) (map[string]map[string]string, string, error) {
+
+	sd := map[string]map[string]string{}
+
+	for strings.HasPrefix(s, "[") {
+		s = s[1:]
+
+		end := strings.IndexAny(s, " ]")
+		if end <= 0 {
+			return nil, "", fmt.Errorf("invalid structured data element")
+		}
+		id := s[:end]
+		params := map[string]string{}
+		sd[id] = params
+		s = s[end:]
+
+		for {
+			s = strings.TrimLeft(s, " ")
+			if s == "" {
+				return nil, "", fmt.Errorf("unterminated structured data element %s", id)
+			}
+			if s[0] == ']' {
+				s = s[1:]
+				break
+			}
+
+			eq := strings.IndexByte(s, '=')
+			if eq <= 0 || eq+1 >= len(s) || s[eq+1] != '"' {
+				return nil, "", fmt.Errorf("invalid parameter in structured data element %s", id)
+			}
+			name := s[:eq]
+			s = s[eq+2:]
+
+			var value strings.Builder
+			closed := false
+			for i := 0; i < len(s); i++ {
+				if s[i] == '\\' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\' || s[i+1] == ']') {
+					value.WriteByte(s[i+1])
+					i++
+					continue
+				}
+				if s[i] == '"' {
+					s = s[i+1:]
+					closed = true
+					break
+				}
+				value.WriteByte(s[i])
+			}
+			if !closed {
+				return nil, "", fmt.Errorf("unterminated parameter %s in structured data element %s", name, id)
+			}
+			params[name] = value.String()
+		}
+	}
+
+	return sd, s, nil
+}
+
+func (m *Message) parseRFC3164(s string, now time.Time) error {
+
+	// high precision timestamps as written by rsyslog, e.g.
+	// 2026-10-17T10:00:00.123456+02:00 host app[42]: message
+	if ts, rest := nextField(s); len(ts) > 10 && ts[4] == '-' && ts[10] == 'T' {
+		t, err := time.Parse(time.RFC3339Nano, ts)
+		if err != nil {
+			return filter.ParseErrorf(filter.ReasonTime, "invalid timestamp %q", ts)
+		}
+		m.Time = t
+		s = rest
+	} else {
+		const layout = "Jan _2 15:04:05"
+		if len(s) < len(layout) {
+			return filter.ParseErrorf(filter.ReasonTime, "missing timestamp")
+		}
+		t, err := time.ParseInLocation(layout, s[:len(layout)], now.Location())
+		if err != nil {
+			return filter.ParseErrorf(filter.ReasonTime, "invalid timestamp %q", s[:len(layout)])
+		}
+		t = t.AddDate(now.Year(), 0, 0)
+		// messages of the last days of the previous year
+		if t.After(now.AddDate(0, 1, 0)) {
+			t = t.AddDate(-1, 0, 0)
+		}
+		m.Time = t
+		s = s[len(layout):]
+	}
+
+	m.Hostname, s = nextField(s)
+
+	// TAG is the app name optionally followed by [pid] and terminated by ':'
+	tag, rest := nextField(s)
+	if strings.HasSuffix(tag, ":") {
+		tag = tag[:len(tag)-1]
+		if i := strings.IndexByte(tag, '['); i > 0 && strings.HasSuffix(tag, "]") {
+			m.ProcID = tag[i+1 : len(tag)-1]
+			tag = tag[:i]
+		}
+		m.AppName = tag
+		s = rest
+	}
+
+	m.Message = strings.TrimLeft(s, " ")
+
+	return nil
+}
+
+// parseError returns a parse error of the line keeping the reason of err,
+// a format error by default.
+func parseError(line string, err error) error {
+	reason := filter.ErrorReason(err)
+	if reason == filter.ReasonUnknown {
+		reason = filter.ReasonFormat
+	}
+	return filter.ParseErrorf(reason, "could not parse line: '%s'; %s", line, err)
+}
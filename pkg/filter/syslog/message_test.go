@@ -0,0 +1,64 @@
+package syslog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRFC5424(t *testing.T) {
+	line := `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][examplePriority@32473 class="high"] An application event`
+
+	m, err := Parse(line, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Facility != "local4" || m.Severity != "notice" || m.Version != 1 {
+		t.Fatalf("unexpected priority %s.%s version %d", m.Facility, m.Severity, m.Version)
+	}
+	if !m.Time.Equal(time.Date(2003, time.October, 11, 22, 14, 15, 3000000, time.UTC)) {
+		t.Fatalf("unexpected time %s", m.Time)
+	}
+	if m.Hostname != "mymachine.example.com" || m.AppName != "evntslog" || m.ProcID != "-" || m.MsgID != "ID47" {
+		t.Fatalf("unexpected header %+v", m)
+	}
+	if m.StructuredData["exampleSDID@32473"]["eventSource"] != "Application" || m.StructuredData["examplePriority@32473"]["class"] != "high" {
+		t.Fatalf("unexpected structured data %v", m.StructuredData)
+	}
+	if m.Message != "An application event" {
+		t.Fatalf("unexpected message %q", m.Message)
+	}
+}
+
+func TestParseRFC3164(t *testing.T) {
+	now := time.Date(2026, time.January, 2, 0, 0, 0, 0, time.UTC)
+
+	m, err := Parse(`<34>Dec 31 22:14:15 mymachine su[123]: 'su root' failed for lonvick on /dev/pts/8`, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Facility != "auth" || m.Severity != "crit" {
+		t.Fatalf("unexpected priority %s.%s", m.Facility, m.Severity)
+	}
+	if !m.Time.Equal(time.Date(2025, time.December, 31, 22, 14, 15, 0, time.UTC)) {
+		t.Fatalf("unexpected time %s", m.Time)
+	}
+	if m.Hostname != "mymachine" || m.AppName != "su" || m.ProcID != "123" {
+		t.Fatalf("unexpected header %+v", m)
+	}
+	if m.Message != "'su root' failed for lonvick on /dev/pts/8" {
+		t.Fatalf("unexpected message %q", m.Message)
+	}
+
+	m, err = Parse(`Jan  2 00:00:01 host kernel: [    0.000000] Linux version`, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Facility != unknown || m.AppName != "kernel" || m.Hostname != "host" {
+		t.Fatalf("unexpected message %+v", m)
+	}
+
+	if _, err := Parse(`<999>Jan  2 00:00:01 host kernel: x`, now); err == nil {
+		t.Fatal("expected error for invalid PRI")
+	}
+}
@@ -0,0 +1,129 @@
+package syslog
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/almariah/ltop/pkg/filter"
+	"github.com/almariah/ltop/pkg/metrics"
+	"github.com/almariah/ltop/pkg/printer"
+)
+
+func init() {
+	filter.Register(filter.Registration{
+		Name:        "syslog",
+		Description: "syslog messages in RFC 3164 or RFC 5424 format, with or without PRI, optionally octet-counted",
+		New: func(opts filter.Options) (filter.Filter, error) {
+			return NewSyslogFilter(), nil
+		},
+	})
+}
+
+// metrics
+var (
+	messageCounter = metrics.NewCounterVec(
+		"syslog_messages_total",
+		"Counter of syslog messages broken out for each facility, severity, host and app.",
+		[]string{"facility", "severity", "host", "app"},
+	)
+)
+
+type SyslogFilter struct {
+	mtx      sync.Mutex
+	deframer deframer
+}
+
+func NewSyslogFilter() *SyslogFilter {
+	return &SyslogFilter{}
+}
+
+func (f *SyslogFilter) RegisterMetrics() {
+	metrics.Register(messageCounter)
+}
+
+func (f *SyslogFilter) RegisterMonitors() {
+}
+
+// HandleEntry handles the messages of a line, which are several if the
+// messages are octet-counted (RFC 6587) as sent over TCP. The first error is
+// returned.
+func (f *SyslogFilter) HandleEntry(time time.Time, entry string) error {
+
+	f.mtx.Lock()
+	messages, err := f.deframer.split(entry)
+	f.mtx.Unlock()
+
+	for _, m := range messages {
+		if merr := f.handleMessage(time, m); merr != nil && err == nil {
+			err = merr
+		}
+	}
+
+	return err
+}
+
+func (f *SyslogFilter) handleMessage(time time.Time, entry string) error {
+
+	m, err := Parse(entry, time)
+	if err != nil {
+		return err
+	}
+
+	eventTime := m.Time
+	if eventTime.IsZero() {
+		eventTime = time
+	}
+
+	messageCounter.WithLabelValues(m.Facility, m.Severity, m.Hostname, m.AppName).IncAt(eventTime)
+
+	return nil
+}
+
+func (f *SyslogFilter) Summary(evalInterval int64) printer.Summary {
+
+	var summary printer.Summary
+
+	graph, ok := filter.RateGraph("syslog_messages_total", evalInterval)
+	if !ok {
+		return summary
+	}
+	summary.Graphs = append(summary.Graphs, graph)
+
+	if tb, ok := filter.RateTable("messages per second grouped by app", "syslog_messages_total", "app", evalInterval); ok {
+		summary.Tables = append(summary.Tables, tb)
+	}
+
+	last := filter.EvalIntervalNumber * evalInterval
+	mt := metrics.QueryLast("syslog_messages_total", []metrics.Label{}, last, evalInterval)
+
+	tb := printer.Table{
+		Title:  fmt.Sprintf("messages grouped by severity for last %d seconds", last),
+		Header: []string{"severity", "messages", "messages per second"},
+	}
+
+	bySeverity := map[string]metrics.PointSeries{}
+	for _, s := range metrics.SumBy(mt, []string{"severity"}) {
+		if len(s.Metric) > 0 {
+			bySeverity[s.Metric[0].Value] = s
+		}
+	}
+
+	for _, severity := range append(severities, unknown) {
+		s, ok := bySeverity[severity]
+		if !ok || len(s.Points) == 0 {
+			continue
+		}
+		count := s.Points[len(s.Points)-1] - s.Points[0]
+		r := metrics.Rate(s)
+		tb.Data = append(tb.Data, []string{
+			severity,
+			fmt.Sprintf("%.0f", count),
+			fmt.Sprintf("%f", r.Points[len(r.Points)-1]),
+		})
+	}
+
+	summary.Tables = append(summary.Tables, tb)
+
+	return summary
+}
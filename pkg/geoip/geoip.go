@@ -0,0 +1,140 @@
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/golang/glog"
+)
+
+// Unknown is the country of addresses without a record.
+const Unknown = "-"
+
+// DefaultCacheSize is the number of addresses whose locations are cached.
+const DefaultCacheSize = 10000
+
+// Location is the country and autonomous system of an address. Fields
+// without a record are Unknown or 0.
+type Location struct {
+	// ISO 3166-1 country code
+	Country      string
+	ASN          uint
+	Organization string
+}
+
+// AS returns the autonomous system as "AS<number> <organization>", Unknown
+// if there is none.
+func (l Location) AS() string {
+	if l.ASN == 0 {
+		return Unknown
+	}
+	if l.Organization == "" {
+		return fmt.Sprintf("AS%d", l.ASN)
+	}
+	return fmt.Sprintf("AS%d %s", l.ASN, l.Organization)
+}
+
+// Resolver looks up the locations of addresses in one or more databases,
+// e.g. a country and an ASN database. The first database with a record
+// sets a field.
+type Resolver struct {
+	readers []*Reader
+
+	mtx   sync.Mutex
+	cache *lru
+}
+
+// NewResolver returns a resolver caching the locations of the cacheSize
+// most recently used addresses.
+func NewResolver(cacheSize int, readers ...*Reader) *Resolver {
+	if cacheSize <= 0 {
+		cacheSize = DefaultCacheSize
+	}
+	return &Resolver{
+		readers: readers,
+		cache:   newLRU(cacheSize),
+	}
+}
+
+// OpenResolver opens the databases at paths.
+func OpenResolver(cacheSize int, paths ...string) (*Resolver, error) {
+	var readers []*Reader
+	for _, p := range paths {
+		r, err := Open(p)
+		if err != nil {
+			return nil, err
+		}
+		readers = append(readers, r)
+	}
+	return NewResolver(cacheSize, readers...), nil
+}
+
+// Lookup returns the location of the host. Hosts which are no IP address
+// are unknown.
+func (r *Resolver) Lookup(host string) Location {
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if l, ok := r.cache.get(host); ok {
+		return l
+	}
+
+	l := Location{Country: Unknown}
+	if ip := net.ParseIP(host); ip != nil {
+		for _, reader := range r.readers {
+			record, ok, err := reader.Lookup(ip)
+			if err != nil {
+				glog.V(1).Infof("geoip lookup of %s: %s", host, err)
+				continue
+			}
+			if ok {
+				l.merge(record)
+			}
+		}
+	}
+
+	r.cache.add(host, l)
+	return l
+}
+
+// merge sets the unknown fields of l present in the record of a GeoIP2 or
+// GeoLite2 Country, City or ASN database.
+func (l *Location) merge(record interface{}) {
+
+	m, ok := record.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	if l.Country == Unknown {
+		for _, key := range []string{"country", "registered_country"} {
+			if code, ok := lookupString(m, key, "iso_code"); ok {
+				l.Country = code
+				break
+			}
+		}
+	}
+
+	if l.ASN == 0 {
+		if asn := toUint64(m["autonomous_system_number"]); asn != 0 {
+			l.ASN = uint(asn)
+			l.Organization, _ = m["autonomous_system_organization"].(string)
+		}
+	}
+}
+
+// lookupString returns the string at the path of nested maps.
+func lookupString(m map[string]interface{}, path ...string) (string, bool) {
+	var v interface{} = m
+	for _, key := range path {
+		mm, ok := v.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		v = mm[key]
+	}
+	s, ok := v.(string)
+	return s, ok && s != ""
+}
@@ -0,0 +1,278 @@
+package geoip
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+// testDB writes tiny MaxMind DB files.
+type testDB struct {
+	recordSize uint
+	ipVersion  uint
+	// records are node indexes, emptyRecord or dataRecord|offset
+	nodes [][2]uint32
+	data  []byte
+}
+
+const (
+	emptyRecord = 0xffffffff
+	dataRecord  = 1 << 30
+)
+
+func newTestDB(recordSize, ipVersion uint) *testDB {
+	return &testDB{
+		recordSize: recordSize,
+		ipVersion:  ipVersion,
+		nodes:      [][2]uint32{{emptyRecord, emptyRecord}},
+	}
+}
+
+// insert points the network to the data at offset.
+func (db *testDB) insert(cidr string, offset int) {
+	_, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	ones, _ := n.Mask.Size()
+	ip := n.IP
+	if ip4 := ip.To4(); ip4 != nil && db.ipVersion == 6 {
+		// IPv4 addresses are in ::/96 of IPv6 databases
+		ip = append(make(net.IP, 12), ip4...)
+		ones += 96
+	}
+
+	node := 0
+	for i := 0; i < ones; i++ {
+		bit := (ip[i/8] >> (7 - uint(i%8))) & 1
+		if i == ones-1 {
+			db.nodes[node][bit] = dataRecord | uint32(offset)
+			break
+		}
+		if db.nodes[node][bit] == emptyRecord {
+			db.nodes = append(db.nodes, [2]uint32{emptyRecord, emptyRecord})
+			db.nodes[node][bit] = uint32(len(db.nodes) - 1)
+		}
+		node = int(db.nodes[node][bit])
+	}
+}
+
+// add appends the encoded values to the data section and returns their
+// offset.
+func (db *testDB) add(values ...[]byte) int {
+	offset := len(db.data)
+	for _, v := range values {
+		db.data = append(db.data, v...)
+	}
+	return offset
+}
+
+func (db *testDB) bytes() []byte {
+
+	nodeCount := uint32(len(db.nodes))
+	value := func(r uint32) uint32 {
+		switch {
+		case r == emptyRecord:
+			return nodeCount
+		case r&dataRecord != 0:
+			return nodeCount + dataSectionSeparatorSize + r&^dataRecord
+		}
+		return r
+	}
+
+	var buf []byte
+	for _, n := range db.nodes {
+		l, r := value(n[0]), value(n[1])
+		switch db.recordSize {
+		case 24:
+			buf = append(buf, byte(l>>16), byte(l>>8), byte(l), byte(r>>16), byte(r>>8), byte(r))
+		case 28:
+			buf = append(buf, byte(l>>16), byte(l>>8), byte(l), byte(l>>24)<<4|byte(r>>24)&0x0f, byte(r>>16), byte(r>>8), byte(r))
+		case 32:
+			buf = append(buf, byte(l>>24), byte(l>>16), byte(l>>8), byte(l), byte(r>>24), byte(r>>16), byte(r>>8), byte(r))
+		}
+	}
+	buf = append(buf, make([]byte, dataSectionSeparatorSize)...)
+	buf = append(buf, db.data...)
+	buf = append(buf, metadataStart...)
+	buf = append(buf, encMap(5)...)
+	buf = append(buf, encString("node_count")...)
+	buf = append(buf, encUint32(nodeCount)...)
+	buf = append(buf, encString("record_size")...)
+	buf = append(buf, encUint16(uint16(db.recordSize))...)
+	buf = append(buf, encString("ip_version")...)
+	buf = append(buf, encUint16(uint16(db.ipVersion))...)
+	buf = append(buf, encString("database_type")...)
+	buf = append(buf, encString("ltop-test")...)
+	buf = append(buf, encString("build_epoch")...)
+	buf = append(buf, encUint64(1600000000)...)
+	return buf
+}
+
+func encString(s string) []byte {
+	if len(s) < 29 {
+		return append([]byte{typeString<<5 | byte(len(s))}, s...)
+	}
+	return append([]byte{typeString<<5 | 29, byte(len(s) - 29)}, s...)
+}
+
+func encMap(size int) []byte {
+	return []byte{typeMap<<5 | byte(size)}
+}
+
+func encUint16(v uint16) []byte {
+	return []byte{typeUint16<<5 | 2, byte(v >> 8), byte(v)}
+}
+
+func encUint32(v uint32) []byte {
+	b := []byte{typeUint32<<5 | 4, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(b[1:], v)
+	return b
+}
+
+func encUint64(v uint64) []byte {
+	b := []byte{typeExtended<<5 | 8, typeUint64 - 7, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint64(b[2:], v)
+	return b
+}
+
+func encPointer(offset int) []byte {
+	return []byte{typePointer<<5 | byte(offset>>8)&0x7, byte(offset)}
+}
+
+// countryDB has US for 8.8.8.0/24 and 2001:db8::/32, and a registered
+// country GB for 81.2.69.0/24 which points to the country of another record.
+func countryDB(recordSize uint) []byte {
+	db := newTestDB(recordSize, 6)
+
+	us := db.add(encMap(1), encString("country"), encMap(1), encString("iso_code"), encString("US"))
+	gb := db.add(encMap(1), encString("iso_code"), encString("GB"))
+	registered := db.add(encMap(1), encString("registered_country"), encPointer(gb))
+
+	db.insert("8.8.8.0/24", us)
+	db.insert("2001:db8::/32", us)
+	db.insert("81.2.69.0/24", registered)
+
+	return db.bytes()
+}
+
+// asnDB is an IPv4 database with the AS of 8.8.8.0/24.
+func asnDB(recordSize uint) []byte {
+	db := newTestDB(recordSize, 4)
+
+	google := db.add(encMap(2),
+		encString("autonomous_system_number"), encUint32(15169),
+		encString("autonomous_system_organization"), encString("Google LLC, a very long organization"))
+
+	db.insert("8.8.0.0/16", google)
+
+	return db.bytes()
+}
+
+func TestReader(t *testing.T) {
+
+	for _, size := range []uint{24, 28, 32} {
+
+		r, err := NewReader(countryDB(size))
+		if err != nil {
+			t.Fatalf("record size %d: %s", size, err)
+		}
+		if r.Metadata.RecordSize != size || r.Metadata.IPVersion != 6 || r.Metadata.DatabaseType != "ltop-test" || r.Metadata.BuildEpoch != 1600000000 {
+			t.Fatalf("record size %d: unexpected metadata %+v", size, r.Metadata)
+		}
+
+		tests := []struct {
+			ip    string
+			found bool
+			exp   string
+		}{
+			{"8.8.8.8", true, "US"},
+			{"8.8.4.4", false, ""},
+			{"2001:db8::1", true, "US"},
+			{"2001:db9::1", false, ""},
+			{"81.2.69.160", true, "GB"},
+		}
+
+		for _, test := range tests {
+			record, ok, err := r.Lookup(net.ParseIP(test.ip))
+			if err != nil {
+				t.Fatalf("record size %d: lookup of %s: %s", size, test.ip, err)
+			}
+			if ok != test.found {
+				t.Errorf("record size %d: lookup of %s: expected found %t, got %t", size, test.ip, test.found, ok)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			var l Location
+			l.Country = Unknown
+			l.merge(record)
+			if l.Country != test.exp {
+				t.Errorf("record size %d: country of %s: expected %s, got %s", size, test.ip, test.exp, l.Country)
+			}
+		}
+	}
+
+	if _, err := NewReader([]byte("not a database")); err == nil {
+		t.Error("expected an error for a file without metadata")
+	}
+}
+
+func TestResolver(t *testing.T) {
+
+	country, err := NewReader(countryDB(24))
+	if err != nil {
+		t.Fatal(err)
+	}
+	asn, err := NewReader(asnDB(28))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewResolver(2, country, asn)
+
+	tests := []struct {
+		host string
+		exp  Location
+		as   string
+	}{
+		{"8.8.8.8", Location{"US", 15169, "Google LLC, a very long organization"}, "AS15169 Google LLC, a very long organization"},
+		{"8.8.4.4", Location{Unknown, 15169, "Google LLC, a very long organization"}, "AS15169 Google LLC, a very long organization"},
+		{"2001:db8::1", Location{"US", 0, ""}, Unknown},
+		{"example.com", Location{Unknown, 0, ""}, Unknown},
+		{"8.8.8.8", Location{"US", 15169, "Google LLC, a very long organization"}, "AS15169 Google LLC, a very long organization"},
+	}
+
+	for _, test := range tests {
+		l := r.Lookup(test.host)
+		if l != test.exp {
+			t.Errorf("location of %s: expected %+v, got %+v", test.host, test.exp, l)
+		}
+		if l.AS() != test.as {
+			t.Errorf("AS of %s: expected %q, got %q", test.host, test.as, l.AS())
+		}
+	}
+
+	if n := r.cache.len(); n != 2 {
+		t.Errorf("expected 2 cached locations, got %d", n)
+	}
+}
+
+func TestLRU(t *testing.T) {
+
+	c := newLRU(2)
+	c.add("a", Location{Country: "A"})
+	c.add("b", Location{Country: "B"})
+	c.get("a")
+	c.add("c", Location{Country: "C"})
+
+	if _, ok := c.get("b"); ok {
+		t.Error("expected the least recently used entry to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.get(key); !ok {
+			t.Errorf("expected %s to be cached", key)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package geoip
+
+import (
+	"container/list"
+)
+
+// lru is a cache of the locations of the most recently used addresses.
+type lru struct {
+	capacity int
+	entries  map[string]*list.Element
+	// the most recently used entry is at the front
+	order *list.List
+}
+
+type lruEntry struct {
+	key      string
+	location Location
+}
+
+func newLRU(capacity int) *lru {
+	return &lru{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+func (c *lru) get(key string) (Location, bool) {
+	e, ok := c.entries[key]
+	if !ok {
+		return Location{}, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*lruEntry).location, true
+}
+
+func (c *lru) add(key string, location Location) {
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*lruEntry).location = location
+		c.order.MoveToFront(e)
+		return
+	}
+	if c.order.Len() >= c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, location: location})
+}
+
+func (c *lru) len() int {
+	return c.order.Len()
+}
@@ -0,0 +1,387 @@
+// Package geoip reads MaxMind DB (.mmdb) files such as GeoLite2-Country and
+// GeoLite2-ASN without cgo or third-party dependencies.
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+)
+
+// marks the start of the metadata at the end of the file
+var metadataStart = []byte("\xab\xcd\xefMaxMind.com")
+
+// the metadata is within the last 128KiB of the file
+const metadataMaxSize = 128 * 1024
+
+// size of the zero bytes between the search tree and the data section
+const dataSectionSeparatorSize = 16
+
+// data types of the data section
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// Metadata describes a database.
+type Metadata struct {
+	NodeCount    uint
+	RecordSize   uint
+	IPVersion    uint
+	DatabaseType string
+	BuildEpoch   uint64
+}
+
+// Reader looks up the records of IP addresses in a database held in memory.
+type Reader struct {
+	Metadata Metadata
+
+	buf  []byte
+	tree []byte
+	data []byte
+
+	// node of the IPv4 subtree of an IPv6 database, ::/96
+	ipv4Start uint
+}
+
+// Open reads the database at path.
+func Open(path string) (*Reader, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewReader(buf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return r, nil
+}
+
+// NewReader returns a reader of the database in buf.
+func NewReader(buf []byte) (*Reader, error) {
+
+	from := 0
+	if len(buf) > metadataMaxSize {
+		from = len(buf) - metadataMaxSize
+	}
+	i := bytes.LastIndex(buf[from:], metadataStart)
+	if i < 0 {
+		return nil, fmt.Errorf("invalid MaxMind DB: metadata not found")
+	}
+	metaStart := from + i + len(metadataStart)
+
+	d := decoder{buf: buf[metaStart:]}
+	v, _, err := d.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MaxMind DB metadata: %s", err)
+	}
+	meta, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid MaxMind DB metadata: not a map")
+	}
+
+	r := &Reader{buf: buf}
+	r.Metadata.NodeCount = uint(toUint64(meta["node_count"]))
+	r.Metadata.RecordSize = uint(toUint64(meta["record_size"]))
+	r.Metadata.IPVersion = uint(toUint64(meta["ip_version"]))
+	r.Metadata.BuildEpoch = toUint64(meta["build_epoch"])
+	r.Metadata.DatabaseType, _ = meta["database_type"].(string)
+
+	switch r.Metadata.RecordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("invalid MaxMind DB: unsupported record size %d", r.Metadata.RecordSize)
+	}
+	if r.Metadata.IPVersion != 4 && r.Metadata.IPVersion != 6 {
+		return nil, fmt.Errorf("invalid MaxMind DB: unsupported IP version %d", r.Metadata.IPVersion)
+	}
+
+	treeSize := r.Metadata.NodeCount * r.Metadata.RecordSize / 4
+	dataStart := treeSize + dataSectionSeparatorSize
+	if dataStart > uint(from+i) {
+		return nil, fmt.Errorf("invalid MaxMind DB: search tree exceeds the file")
+	}
+	r.tree = buf[:treeSize]
+	r.data = buf[dataStart : from+i]
+
+	if r.Metadata.IPVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.Metadata.NodeCount; i++ {
+			node = r.record(node, 0)
+		}
+		r.ipv4Start = node
+	}
+
+	return r, nil
+}
+
+// record returns the left (bit 0) or right (bit 1) record of the node.
+func (r *Reader) record(node uint, bit uint) uint {
+	switch r.Metadata.RecordSize {
+	case 24:
+		b := r.tree[node*6+bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := r.tree[node*7:]
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		b := r.tree[node*8+bit*4:]
+		return uint(binary.BigEndian.Uint32(b))
+	}
+}
+
+// Lookup returns the record of ip, false if the database has no record of
+// it.
+func (r *Reader) Lookup(ip net.IP) (interface{}, bool, error) {
+
+	node := uint(0)
+	bits := 128
+
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		bits = 32
+		if r.Metadata.IPVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else if r.Metadata.IPVersion == 4 {
+		return nil, false, nil
+	}
+
+	nodeCount := r.Metadata.NodeCount
+	for i := 0; i < bits && node < nodeCount; i++ {
+		bit := uint(ip[i>>3]>>(7-uint(i&7))) & 1
+		node = r.record(node, bit)
+	}
+
+	if node == nodeCount {
+		return nil, false, nil
+	}
+	if node < nodeCount {
+		return nil, false, fmt.Errorf("invalid MaxMind DB: search tree is deeper than the address")
+	}
+
+	offset := node - nodeCount - dataSectionSeparatorSize
+	if offset >= uint(len(r.data)) {
+		return nil, false, fmt.Errorf("invalid MaxMind DB: record pointer %d exceeds the data section", offset)
+	}
+
+	d := decoder{buf: r.data}
+	v, _, err := d.decode(offset)
+	if err != nil {
+		return nil, false, err
+	}
+	return v, true, nil
+}
+
+// decoder decodes the values of a data section.
+type decoder struct {
+	buf []byte
+}
+
+// the depth of nested maps and arrays decoded
+const maxDepth = 32
+
+// decode returns the value at offset and the offset following it.
+func (d *decoder) decode(offset uint) (interface{}, uint, error) {
+	return d.decodeDepth(offset, 0)
+}
+
+func (d *decoder) decodeDepth(offset uint, depth int) (interface{}, uint, error) {
+
+	if depth > maxDepth {
+		return nil, 0, fmt.Errorf("data nested too deeply")
+	}
+
+	typ, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if typ == typePointer {
+		pointer, next, err := d.pointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, _, err := d.decodeDepth(pointer, depth+1)
+		return v, next, err
+	}
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			k, next, err := d.decodeDepth(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("map key at offset %d is no string", offset)
+			}
+			v, next, err := d.decodeDepth(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+			offset = next
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			v, next, err := d.decodeDepth(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+			offset = next
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(d.buf)) {
+		return nil, 0, fmt.Errorf("value at offset %d exceeds the data", offset)
+	}
+	b := d.buf[offset : offset+size]
+	next := offset + size
+
+	switch typ {
+	case typeString:
+		return string(b), next, nil
+	case typeBytes:
+		return append([]byte{}, b...), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid double size %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("invalid float size %d", size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), next, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("invalid unsigned integer size %d", size)
+		}
+		return uintValue(b), next, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("invalid int32 size %d", size)
+		}
+		return int64(int32(uintValue(b))), next, nil
+	case typeUint128:
+		// kept as bytes, no lookups need their value
+		return append([]byte{}, b...), next, nil
+	}
+
+	return nil, 0, fmt.Errorf("unsupported data type %d at offset %d", typ, offset)
+}
+
+// control returns type and size of the value at offset and the offset of
+// its payload. The size of pointers is the raw size bits.
+func (d *decoder) control(offset uint) (int, uint, uint, error) {
+
+	if offset >= uint(len(d.buf)) {
+		return 0, 0, 0, fmt.Errorf("offset %d exceeds the data", offset)
+	}
+	ctrl := d.buf[offset]
+	offset++
+
+	typ := int(ctrl >> 5)
+	if typ == typePointer {
+		return typ, uint(ctrl & 0x1f), offset, nil
+	}
+	if typ == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return 0, 0, 0, fmt.Errorf("offset %d exceeds the data", offset)
+		}
+		typ = 7 + int(d.buf[offset])
+		offset++
+	}
+
+	size := uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > uint(len(d.buf)) {
+			return 0, 0, 0, fmt.Errorf("size at offset %d exceeds the data", offset)
+		}
+		b := d.buf[offset : offset+n]
+		offset += n
+		switch n {
+		case 1:
+			size = 29 + uint(b[0])
+		case 2:
+			size = 285 + (uint(b[0])<<8 | uint(b[1]))
+		default:
+			size = 65821 + (uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]))
+		}
+	}
+
+	return typ, size, offset, nil
+}
+
+// pointer returns the offset the pointer with the given size bits points
+// to and the offset following the pointer.
+func (d *decoder) pointer(bits uint, offset uint) (uint, uint, error) {
+
+	n := (bits>>3)&0x3 + 1
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, fmt.Errorf("pointer at offset %d exceeds the data", offset)
+	}
+	b := d.buf[offset : offset+n]
+
+	var p uint
+	switch n {
+	case 1:
+		p = (bits&0x7)<<8 | uint(b[0])
+	case 2:
+		p = ((bits&0x7)<<16 | uint(b[0])<<8 | uint(b[1])) + 2048
+	case 3:
+		p = ((bits&0x7)<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])) + 526336
+	default:
+		p = uint(binary.BigEndian.Uint32(b))
+	}
+
+	return p, offset + n, nil
+}
+
+func uintValue(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func toUint64(v interface{}) uint64 {
+	switch n := v.(type) {
+	case uint64:
+		return n
+	case int64:
+		return uint64(n)
+	}
+	return 0
+}
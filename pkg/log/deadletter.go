@@ -0,0 +1,119 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// DeadLetterFile receives the raw lines which could not be parsed. Once the
+// file would exceed maxSize it is rotated to path.1, path.1 to path.2 and so
+// on, keeping at most maxBackups rotated files.
+type DeadLetterFile struct {
+	mtx sync.Mutex
+
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	file *os.File
+	size int64
+}
+
+// NewDeadLetterFile opens or creates the dead-letter file at path.
+func NewDeadLetterFile(path string, maxSize int64, maxBackups int) (*DeadLetterFile, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("dead-letter file size must be positive")
+	}
+	d := &DeadLetterFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := d.open(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *DeadLetterFile) open() error {
+	f, err := os.OpenFile(d.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	d.file = f
+	d.size = fi.Size()
+	return nil
+}
+
+// Write writes p to the file, rotating it first if p would exceed the size.
+// Writes larger than the size are truncated.
+func (d *DeadLetterFile) Write(p []byte) (int, error) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if d.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	n := len(p)
+	if int64(len(p)) > d.maxSize {
+		p = p[:d.maxSize]
+	}
+
+	if d.size+int64(len(p)) > d.maxSize && d.size > 0 {
+		if err := d.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	written, err := d.file.Write(p)
+	d.size += int64(written)
+	if err != nil {
+		return written, err
+	}
+	return n, nil
+}
+
+func (d *DeadLetterFile) rotate() error {
+	if err := d.file.Close(); err != nil {
+		return err
+	}
+	d.file = nil
+
+	if d.maxBackups <= 0 {
+		if err := os.Remove(d.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return d.open()
+	}
+
+	for i := d.maxBackups - 1; i > 0; i-- {
+		from := fmt.Sprintf("%s.%d", d.path, i)
+		if err := os.Rename(from, fmt.Sprintf("%s.%d", d.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(d.path, d.path+".1"); err != nil {
+		return err
+	}
+
+	return d.open()
+}
+
+func (d *DeadLetterFile) Close() error {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if d.file == nil {
+		return nil
+	}
+	err := d.file.Close()
+	d.file = nil
+	return err
+}
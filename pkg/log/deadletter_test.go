@@ -0,0 +1,46 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDeadLetterFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deadletter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "unparsed.log")
+	d, err := NewDeadLetterFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"aaaa\n", "bbbb\n", "cccc\n", "dddd\n", "eeee\n", "ffff\n", "gggg\n"} {
+		if _, err := d.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d.Close()
+
+	exp := map[string]string{
+		path:        "gggg\n",
+		path + ".1": "eeee\nffff\n",
+		path + ".2": "cccc\ndddd\n",
+	}
+	for p, content := range exp {
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != content {
+			t.Errorf("%s: expected %q, got %q", p, content, b)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected at most 2 rotated files")
+	}
+}
@@ -0,0 +1,97 @@
+package log
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// MultilineConfig configures how physical lines are joined into entries.
+// A line matching Start begins a new entry, a line matching Continue is
+// appended to the current entry. If only Start is set every other line is
+// a continuation, if only Continue is set every other line begins a new
+// entry.
+type MultilineConfig struct {
+	Start    *regexp.Regexp
+	Continue *regexp.Regexp
+	// maximum number of lines of an entry, further lines begin a new entry
+	MaxLines int
+	// an entry is flushed if no line follows within Timeout
+	Timeout time.Duration
+}
+
+// Entry is a logical log entry made of one or more lines.
+type Entry struct {
+	Time time.Time
+	Text string
+}
+
+type MultilineJoiner struct {
+	config MultilineConfig
+
+	lines []string
+	time  time.Time
+}
+
+func NewMultilineJoiner(config MultilineConfig) (*MultilineJoiner, error) {
+	if config.Start == nil && config.Continue == nil {
+		return nil, fmt.Errorf("multiline requires a start or continuation pattern")
+	}
+	return &MultilineJoiner{
+		config: config,
+	}, nil
+}
+
+// Add adds a line and returns the entries completed by it.
+func (j *MultilineJoiner) Add(t time.Time, line string) []Entry {
+
+	var result []Entry
+
+	if len(j.lines) > 0 && !j.continues(line) {
+		result = append(result, j.flush())
+	}
+
+	if len(j.lines) == 0 {
+		j.time = t
+	}
+	j.lines = append(j.lines, line)
+
+	if j.config.MaxLines > 0 && len(j.lines) >= j.config.MaxLines {
+		result = append(result, j.flush())
+	}
+
+	return result
+}
+
+func (j *MultilineJoiner) continues(line string) bool {
+	if j.config.Start != nil && j.config.Start.MatchString(line) {
+		return false
+	}
+	if j.config.Continue != nil {
+		return j.config.Continue.MatchString(line)
+	}
+	return true
+}
+
+// Pending reports whether lines are waiting for the entry to complete.
+func (j *MultilineJoiner) Pending() bool {
+	return len(j.lines) > 0
+}
+
+// Flush returns the pending entry, if any.
+func (j *MultilineJoiner) Flush() (Entry, bool) {
+	if len(j.lines) == 0 {
+		return Entry{}, false
+	}
+	return j.flush(), true
+}
+
+func (j *MultilineJoiner) flush() Entry {
+	e := Entry{
+		Time: j.time,
+		Text: strings.Join(j.lines, "\n"),
+	}
+	j.lines = j.lines[:0]
+	return e
+}
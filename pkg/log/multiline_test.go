@@ -0,0 +1,66 @@
+package log
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestMultilineJoiner(t *testing.T) {
+	j, err := NewMultilineJoiner(MultilineConfig{
+		Start:    regexp.MustCompile(`^\d{4}-\d{2}-\d{2} `),
+		MaxLines: 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []Entry
+	for _, line := range []string{
+		"2026-10-17 ERROR boom",
+		"java.lang.NullPointerException",
+		"\tat Foo.bar(Foo.java:1)",
+		"\tat Foo.main(Foo.java:2)",
+		"2026-10-17 INFO ok",
+	} {
+		entries = append(entries, j.Add(time.Time{}, line)...)
+	}
+
+	exp := []string{
+		"2026-10-17 ERROR boom\njava.lang.NullPointerException\n\tat Foo.bar(Foo.java:1)",
+		"\tat Foo.main(Foo.java:2)",
+	}
+	if len(entries) != len(exp) {
+		t.Fatalf("expected %d entries, got %d: %q", len(exp), len(entries), entries)
+	}
+	for i, e := range entries {
+		if e.Text != exp[i] {
+			t.Fatalf("unexpected entry %d: %q", i, e.Text)
+		}
+	}
+
+	e, ok := j.Flush()
+	if !ok || e.Text != "2026-10-17 INFO ok" {
+		t.Fatalf("unexpected flushed entry %q", e.Text)
+	}
+	if _, ok := j.Flush(); ok {
+		t.Fatal("expected no pending entry")
+	}
+}
+
+func TestMultilineJoinerContinue(t *testing.T) {
+	j, err := NewMultilineJoiner(MultilineConfig{
+		Continue: regexp.MustCompile(`^\s`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []Entry
+	for _, line := range []string{"a", " b", "c", "d", " e"} {
+		entries = append(entries, j.Add(time.Time{}, line)...)
+	}
+	if len(entries) != 2 || entries[0].Text != "a\n b" || entries[1].Text != "c" {
+		t.Fatalf("unexpected entries %q", entries)
+	}
+}
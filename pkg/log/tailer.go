@@ -1,26 +1,61 @@
 package log
 
 import (
+	"os"
+	"time"
 	"sync"
 	"github.com/hpcloud/tail"
 	"github.com/almariah/ltop/pkg/filter"
+	"github.com/almariah/ltop/pkg/metrics"
 	"github.com/golang/glog"
 )
 
+// interval the lag of the tailer is measured at
+const lagInterval = time.Second
+
+// metrics
+var (
+
+	lagGauge = metrics.NewGauge(
+		"ltop_tailer_lag_bytes",
+		"Number of bytes of the log file not read yet.",
+	)
+
+)
+
+// RegisterMetrics registers the metrics of the tailer.
+func RegisterMetrics() {
+	metrics.Register(lagGauge)
+}
+
 type tailer struct {
 	filter filter.Filter
 
 	path string
 	tail *tail.Tail
 
+	// joins lines into entries, nil if every line is an entry
+	joiner *MultilineJoiner
+
 	posAndSizeMtx sync.Mutex
 
 	quit chan struct{}
 	done chan struct{}
 }
 
-func NewTailer(filter filter.Filter, path string) (*tailer, error) {
-	
+// NewTailer starts tailing the file at path. If multiline is not nil lines
+// are joined into entries before they are handled by the filter.
+func NewTailer(filter filter.Filter, path string, multiline *MultilineConfig) (*tailer, error) {
+
+	var joiner *MultilineJoiner
+	if multiline != nil {
+		j, err := NewMultilineJoiner(*multiline)
+		if err != nil {
+			return nil, err
+		}
+		joiner = j
+	}
+
 	tail, err := tail.TailFile(path, tail.Config{
 		Follow: true,
 		Poll:   true,
@@ -40,6 +75,7 @@ func NewTailer(filter filter.Filter, path string) (*tailer, error) {
 
 		path: path,
 		tail: tail,
+		joiner: joiner,
 		quit: make(chan struct{}),
 		done: make(chan struct{}),
 	}
@@ -54,11 +90,18 @@ func (t *tailer) run() {
 		close(t.done)
 	}()
 
+	// fires when a pending multiline entry is not continued in time
+	var flush <-chan time.Time
+
+	lag := time.NewTicker(lagInterval)
+	defer lag.Stop()
+
 	for {
 		select {
 
 		case line, ok := <-t.tail.Lines:
 			if !ok {
+				t.flush()
 				return
 			}
 
@@ -66,15 +109,72 @@ func (t *tailer) run() {
 				glog.Error(line.Err)
 			}
 
-			if err := t.filter.HandleEntry(line.Time, line.Text); err != nil {
-				glog.Error(err)
+			if t.joiner == nil {
+				t.handle(Entry{Time: line.Time, Text: line.Text})
+				continue
+			}
+
+			for _, e := range t.joiner.Add(line.Time, line.Text) {
+				t.handle(e)
+			}
+
+			flush = nil
+			if t.joiner.Pending() && t.joiner.config.Timeout > 0 {
+				flush = time.After(t.joiner.config.Timeout)
 			}
+		case <-flush:
+			t.flush()
+			flush = nil
+		case <-lag.C:
+			t.updateLag()
 		case <-t.quit:
+			t.flush()
 			return
 		}
 	}
 }
 
+// flush handles the pending multiline entry
+func (t *tailer) flush() {
+	if t.joiner == nil {
+		return
+	}
+	if e, ok := t.joiner.Flush(); ok {
+		t.handle(e)
+	}
+}
+
+// updateLag sets the lag gauge to the number of bytes between the read
+// offset and the end of the file.
+func (t *tailer) updateLag() {
+	t.posAndSizeMtx.Lock()
+	defer t.posAndSizeMtx.Unlock()
+
+	offset, err := t.tail.Tell()
+	if err != nil {
+		return
+	}
+	fi, err := os.Stat(t.path)
+	if err != nil {
+		return
+	}
+
+	// the file was truncated or rotated and not reopened yet
+	lag := fi.Size() - offset
+	if lag < 0 {
+		lag = 0
+	}
+	lagGauge.Set(float64(lag))
+}
+
+func (t *tailer) handle(e Entry) {
+	// parse errors are counted by the filter, logging every line would
+	// flood stderr
+	if err := t.filter.HandleEntry(e.Time, e.Text); err != nil {
+		glog.V(1).Info(err)
+	}
+}
+
 func (t *tailer) Stop() error {
 	err := t.tail.Stop()
 	close(t.quit)
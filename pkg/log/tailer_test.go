@@ -0,0 +1,57 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/almariah/ltop/pkg/printer"
+)
+
+// entryFilter passes the handled entries to a channel
+type entryFilter chan string
+
+func (f entryFilter) HandleEntry(time time.Time, entry string) error {
+	f <- entry
+	return nil
+}
+
+func (f entryFilter) Summary(int64) printer.Summary { return printer.Summary{} }
+func (f entryFilter) RegisterMetrics()              {}
+func (f entryFilter) RegisterMonitors()             {}
+
+func TestTailerMultilineTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tailer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the first line of a stack trace which is not continued
+	path := filepath.Join(dir, "app.log")
+	if err := ioutil.WriteFile(path, []byte("2026-10-17 ERROR boom\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f := make(entryFilter, 1)
+	tailer, err := NewTailer(f, path, &MultilineConfig{
+		Start:   regexp.MustCompile(`^\d{4}-\d{2}-\d{2} `),
+		Timeout: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tailer.Stop()
+
+	select {
+	case e := <-f:
+		if e != "2026-10-17 ERROR boom" {
+			t.Fatalf("unexpected entry %q", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending entry not flushed after the timeout")
+	}
+}
@@ -84,9 +84,9 @@ func (a *alertManager) RegisterMonitor(m Monitor) error {
 
 func (a *alertManager) StartAlertManager() {
 
-	for _, m := range a.monitors {
-		go m.start(a.alerts)
-	}	
+	for i := range a.monitors {
+		go a.monitors[i].start(a.alerts)
+	}
 }
 
 func StartAlertManager() {
@@ -99,19 +99,73 @@ func (m *Monitor) start(ch *chan Monitor) {
 		select {
 
 		case <-time.After(time.Duration(m.duration) * time.Second):
-			
-			m.statusTime = time.Now()
-			m.current = m.eval()
-			
-			if m.current >= m.threshold {
-				m.status = "TRIGGERED"
+			if m.evaluate(Now()) {
 				*ch <- *m
-			} else {
-				if m.status == "TRIGGERED" {
-					m.status = "RECOVERED"
-					*ch <- *m
-				}
 			}
 		}
 	}
-}
\ No newline at end of file
+}
+
+// evaluate evaluates the monitor at the given time and reports whether an
+// alert has to be sent, i.e. the monitor is triggered or has recovered.
+func (m *Monitor) evaluate(now time.Time) bool {
+
+	m.statusTime = now
+	m.current = m.eval()
+
+	if m.current >= m.threshold {
+		m.status = "TRIGGERED"
+		return true
+	}
+
+	if m.status == "TRIGGERED" {
+		m.status = "RECOVERED"
+		return true
+	}
+
+	return false
+}
+
+// Duration returns the evaluation interval of the monitor in seconds.
+func (m *Monitor) Duration() int64 {
+	return m.duration
+}
+
+// StatusTime returns the time of the last evaluation of the monitor.
+func (m *Monitor) StatusTime() time.Time {
+	return m.statusTime
+}
+
+// Evaluate evaluates every registered monitor which is due at the given
+// time, e.g. of a simulated clock, and returns the alerts which changed their
+// status in order of evaluation. A monitor is due every duration seconds
+// after its first evaluation.
+func Evaluate(now time.Time) []Monitor {
+	a := defaultAlertManager
+	return a.Evaluate(now)
+}
+
+func (a *alertManager) Evaluate(now time.Time) []Monitor {
+
+	var result []Monitor
+
+	for i := range a.monitors {
+		m := &a.monitors[i]
+
+		// the first evaluation is due duration seconds after now
+		if m.statusTime.IsZero() {
+			m.statusTime = now
+			continue
+		}
+		if now.Sub(m.statusTime) < time.Duration(m.duration)*time.Second {
+			continue
+		}
+
+		status := m.status
+		if m.evaluate(now) && m.status != status {
+			result = append(result, *m)
+		}
+	}
+
+	return result
+}
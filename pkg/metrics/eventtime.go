@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// In event-time mode samples are bucketed by the timestamp of the log entry
+// instead of the time the entry was read. The buckets are collect interval
+// wide and are collected once the newest event seen (the watermark) is
+// past their end by more than the allowed lateness. Events arriving for a
+// bucket which is already collected are dropped.
+type eventTime struct {
+	enabled  bool
+	lateness int64
+
+	// newest event timestamp seen
+	watermark int64
+	// end of the last collected bucket
+	collected int64
+	// number of events dropped as they arrived too late
+	dropped uint64
+}
+
+// SetEventTime enables the event-time mode with the given allowed lateness.
+func SetEventTime(lateness time.Duration) {
+	r := defaultRegistry
+	r.eventTime.enabled = true
+	r.eventTime.lateness = int64(lateness / time.Second)
+}
+
+// EventTime reports whether the event-time mode is enabled.
+func EventTime() bool {
+	return defaultRegistry.eventTime.enabled
+}
+
+// Now returns the current time of the metrics. In event-time mode this is
+// the end of the last collected bucket, otherwise or before the first bucket
+// is collected the wall clock.
+func Now() time.Time {
+	r := defaultRegistry
+	if !r.eventTime.enabled {
+		return time.Now()
+	}
+	if collected := atomic.LoadInt64(&r.eventTime.collected); collected > 0 {
+		return time.Unix(collected, 0)
+	}
+	return time.Now()
+}
+
+// DroppedLateEvents returns the number of events dropped in event-time mode
+// as they arrived later than the allowed lateness.
+func DroppedLateEvents() uint64 {
+	return atomic.LoadUint64(&defaultRegistry.eventTime.dropped)
+}
+
+// bucketWidth returns the width in seconds of the event-time buckets.
+func bucketWidth() int64 {
+	if w := int64(defaultRegistry.collectInterval); w > 0 {
+		return w
+	}
+	return 1
+}
+
+// bucketStart returns the start of the event-time bucket of t.
+func bucketStart(t int64) int64 {
+	w := bucketWidth()
+	return t - ((t%w)+w)%w
+}
+
+// observeEvent advances the watermark to t and reports whether an event at t
+// is still accepted.
+func observeEvent(t int64) bool {
+	e := &defaultRegistry.eventTime
+
+	for {
+		w := atomic.LoadInt64(&e.watermark)
+		if t <= w || atomic.CompareAndSwapInt64(&e.watermark, w, t) {
+			break
+		}
+	}
+
+	if bucketStart(t)+bucketWidth() <= atomic.LoadInt64(&e.collected) {
+		dropEvent()
+		return false
+	}
+	return true
+}
+
+// dropEvent counts an event dropped as it arrived too late.
+func dropEvent() {
+	atomic.AddUint64(&defaultRegistry.eventTime.dropped, 1)
+}
+
+// collectableUntil returns the end of the last bucket which may be collected.
+func (e *eventTime) collectableUntil() int64 {
+	w := atomic.LoadInt64(&e.watermark)
+	if w == 0 {
+		return 0
+	}
+	return bucketStart(w - e.lateness)
+}
+
+// eventMetric is implemented by metrics recording values by event time.
+type eventMetric interface {
+	Metric
+	// collectUntil returns the samples of all buckets ending at or before t
+	// which were not collected yet.
+	collectUntil(t int64) []Sample
+}
+
+// Collectable returns the end of the last event-time bucket which is past
+// the allowed lateness.
+func Collectable() time.Time {
+	return time.Unix(defaultRegistry.eventTime.collectableUntil(), 0)
+}
+
+// Collected returns the end of the last collected event-time bucket, the
+// epoch before the first collection.
+func Collected() time.Time {
+	return time.Unix(atomic.LoadInt64(&defaultRegistry.eventTime.collected), 0)
+}
+
+// Watermark returns the timestamp of the newest event seen.
+func Watermark() time.Time {
+	return time.Unix(atomic.LoadInt64(&defaultRegistry.eventTime.watermark), 0)
+}
+
+// CollectUntil collects the event-time buckets ending at or before t.
+func CollectUntil(t time.Time) {
+	defaultRegistry.CollectUntil(t.Unix())
+}
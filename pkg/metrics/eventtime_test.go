@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCounterEventTime(t *testing.T) {
+	r := defaultRegistry
+	defer func(i int, e eventTime) {
+		r.collectInterval = i
+		r.eventTime = e
+	}(r.collectInterval, r.eventTime)
+
+	r.collectInterval = 10
+	r.eventTime = eventTime{}
+	SetEventTime(5 * time.Second)
+
+	// nothing collected yet
+	if now := Now(); time.Since(now) > time.Minute {
+		t.Fatalf("expected the wall clock before the first collection, got %v", now)
+	}
+
+	c := &counter{}
+	c.IncAt(time.Unix(1003, 0))
+	c.IncAt(time.Unix(1001, 0))
+	c.AddAt(time.Unix(1045, 0), 2)
+
+	// the watermark 1045 less the lateness allows to collect up to 1040
+	if until := r.eventTime.collectableUntil(); until != 1040 {
+		t.Fatalf("unexpected collectable time %d", until)
+	}
+
+	exp := []Sample{{T: 1000, V: 0}, {T: 1010, V: 2}}
+	if s := c.collectUntil(1040); !reflect.DeepEqual(s, exp) {
+		t.Fatalf("unexpected samples %v", s)
+	}
+	r.eventTime.collected = 1040
+
+	if now := Now(); now.Unix() != 1040 {
+		t.Fatalf("unexpected time %v", now)
+	}
+
+	// late event of a collected bucket
+	c.IncAt(time.Unix(1012, 0))
+	if DroppedLateEvents() != 1 {
+		t.Fatalf("expected dropped event")
+	}
+
+	exp = []Sample{{T: 1040, V: 2}, {T: 1050, V: 4}}
+	if s := c.collectUntil(1050); !reflect.DeepEqual(s, exp) {
+		t.Fatalf("unexpected samples %v", s)
+	}
+}
+
+func TestPointsBetween(t *testing.T) {
+	s := NewMemSeries(0, nil, 10000)
+	for _, smpl := range []Sample{{T: 1000, V: 0}, {T: 1010, V: 2}, {T: 1040, V: 2}, {T: 1050, V: 4}} {
+		s.Append(smpl.T, smpl.V)
+	}
+
+	// values before the first sample are omitted, values after the last
+	// sample are carried forward
+	p := s.pointsBetween(990, 1070, 10)
+	exp := []float64{0, 2, 2, 2, 2, 4, 4, 4}
+	if !reflect.DeepEqual(p, exp) {
+		t.Fatalf("unexpected points %v", p)
+	}
+
+	p = s.pointsBetween(1000, 1015, 5)
+	exp = []float64{0, 1, 2, 2}
+	if !reflect.DeepEqual(p, exp) {
+		t.Fatalf("unexpected interpolated points %v", p)
+	}
+}
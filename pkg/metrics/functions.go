@@ -33,7 +33,7 @@ type Matrix []PointSeries
 func Rate(ps PointSeries) PointSeries {
 	result := NewPointSeries(ps.Metric, ps.evalInterval)
 	result.Metric = ps.Metric
-	result.Points = append(result.Points, 0.2)
+	result.Points = append(result.Points, 0)
 	if len(ps.Points) == 0 {
 		result.Points = append(result.Points, 0)
 	}
@@ -47,6 +47,16 @@ func Rate(ps PointSeries) PointSeries {
 	return *result
 }
 
+// AvgRate returns the average rate per second of a counter series over its
+// whole range.
+func AvgRate(ps PointSeries) float64 {
+	if len(ps.Points) < 2 {
+		return 0
+	}
+	increase := ps.Points[len(ps.Points)-1] - ps.Points[0]
+	return increase / float64(ps.evalInterval*int64(len(ps.Points)-1))
+}
+
 func Avg(ps PointSeries) float64 {
 	var sumRate float64
 	for _, p := range ps.Points {
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"sync"
+)
+
+// Gauge is a metric which value can go up and down, e.g. the number of
+// requests in flight or the size of the last response.
+type Gauge interface {
+	Collector
+	Set(float64)
+	Inc()
+	Dec()
+	Add(float64)
+	Sub(float64)
+}
+
+type gauge struct {
+	mtx  sync.Mutex
+	val  float64
+	lset Labels
+	desc *Desc
+}
+
+// NewGauge returns a gauge without labels.
+func NewGauge(name string, help string) Gauge {
+	return &gauge{desc: NewDesc(name, help, nil)}
+}
+
+func (g *gauge) Set(v float64) {
+	g.mtx.Lock()
+	g.val = v
+	g.mtx.Unlock()
+}
+
+func (g *gauge) Inc() {
+	g.Add(1)
+}
+
+func (g *gauge) Dec() {
+	g.Add(-1)
+}
+
+func (g *gauge) Add(v float64) {
+	g.mtx.Lock()
+	g.val += v
+	g.mtx.Unlock()
+}
+
+func (g *gauge) Sub(v float64) {
+	g.Add(-v)
+}
+
+func (g *gauge) Desc() *Desc {
+	return g.desc
+}
+
+func (g *gauge) Value() float64 {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	return g.val
+}
+
+func (g *gauge) Labels() Labels {
+	return g.lset
+}
+
+func (g *gauge) Collect(ch chan<- Metric) {
+	ch <- g
+}
+
+func (g *gauge) Describe(ch chan<- *Desc) {
+	ch <- g.desc
+}
+
+type GaugeVec struct {
+	*metricVec
+}
+
+func NewGaugeVec(name string, help string, labelNames []string) *GaugeVec {
+
+	desc := NewDesc(name, help, labelNames)
+
+	return &GaugeVec{
+		metricVec: newMetricVec(desc, func(lset Labels) Metric {
+			return &gauge{desc: desc, lset: lset}
+		}),
+	}
+}
+
+func (v *GaugeVec) WithLabelValues(lvs ...string) Gauge {
+	return v.getOrCreateMetricWithLabelValues(lvs).(Gauge)
+}
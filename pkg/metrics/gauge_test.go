@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"testing"
+)
+
+func TestGauge(t *testing.T) {
+	g := NewGaugeVec("test_in_flight", "", []string{"section"}).WithLabelValues("/api")
+	g.Set(3)
+	g.Inc()
+	g.Add(2.5)
+	g.Dec()
+	g.Sub(4)
+
+	if v := g.(Metric).Value(); v != 1.5 {
+		t.Fatalf("unexpected value %f", v)
+	}
+}
+
+func TestGaugeVecCollect(t *testing.T) {
+	r := NewRegistry()
+
+	vec := NewGaugeVec("test_requests_in_flight", "", []string{"section"})
+	if err := r.Register(vec); err != nil {
+		t.Fatal(err)
+	}
+	vec.WithLabelValues("/api").Set(3)
+	vec.WithLabelValues("/static").Set(1)
+	vec.WithLabelValues("/static").Dec()
+
+	r.Collect()
+
+	exp := map[string]float64{"/api": 3, "/static": 0}
+	m := r.QueryLast("test_requests_in_flight", Labels{}, 10, 1)
+	if len(m) != len(exp) {
+		t.Fatalf("expected %d series, got %d", len(exp), len(m))
+	}
+	for _, ps := range m {
+		section := ps.Metric[0].Value
+		if len(ps.Points) == 0 {
+			t.Fatalf("no points for %s", section)
+		}
+		if v := ps.Points[len(ps.Points)-1]; v != exp[section] {
+			t.Errorf("expected %v for %s, got %v", exp[section], section, v)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DefBuckets are the default histogram buckets, suited for request
+// durations in seconds.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// LinearBuckets returns count buckets, the lowest bucket has an upper bound
+// of start and each following bucket is width wider.
+func LinearBuckets(start, width float64, count int) []float64 {
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start += width
+	}
+	return buckets
+}
+
+// ExponentialBuckets returns count buckets, the lowest bucket has an upper
+// bound of start and each following bucket is factor times wider.
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+	return buckets
+}
+
+// Histogram counts observations in cumulative buckets. A histogram named
+// x is stored as the series x_bucket{le="<upper bound>"}, x_sum and x_count
+// which are counters, so they are queried like any other counter.
+type Histogram interface {
+	Collector
+	Observe(float64)
+	// ObserveAt records the observation at the time of the event in
+	// event-time mode, it is equal to Observe otherwise.
+	ObserveAt(time.Time, float64)
+}
+
+type histogram struct {
+	desc *Desc
+	lset Labels
+
+	upperBounds []float64
+	// one counter per upper bound and one for +Inf
+	buckets []*counter
+	sum     *counter
+	count   *counter
+}
+
+func newHistogram(desc *Desc, bucketDesc, sumDesc, countDesc *Desc, buckets []float64, lset Labels) *histogram {
+
+	h := &histogram{
+		desc:        desc,
+		lset:        lset,
+		upperBounds: buckets,
+		sum:         &counter{desc: sumDesc, lset: lset, now: time.Now},
+		count:       &counter{desc: countDesc, lset: lset, now: time.Now},
+	}
+
+	for _, b := range append(buckets, math.Inf(1)) {
+		bucketLset := append(append(Labels{}, lset...), Label{Name: "le", Value: formatBound(b)})
+		h.buckets = append(h.buckets, &counter{desc: bucketDesc, lset: bucketLset, now: time.Now})
+	}
+
+	return h
+}
+
+func formatBound(b float64) string {
+	if math.IsInf(b, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(b, 'g', -1, 64)
+}
+
+func checkBuckets(buckets []float64) []float64 {
+	if len(buckets) > 0 && math.IsInf(buckets[len(buckets)-1], 1) {
+		buckets = buckets[:len(buckets)-1]
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Errorf("histogram buckets must be in increasing order: %v", buckets))
+	}
+	return buckets
+}
+
+// NewHistogram returns a histogram without labels. The +Inf bucket is
+// added implicitly.
+func NewHistogram(name string, help string, buckets []float64) Histogram {
+	buckets = checkBuckets(buckets)
+	desc := NewDesc(name, help, nil)
+	return newHistogram(
+		desc,
+		NewDesc(name+"_bucket", help, []string{"le"}),
+		NewDesc(name+"_sum", help, nil),
+		NewDesc(name+"_count", help, nil),
+		buckets,
+		nil,
+	)
+}
+
+func (h *histogram) Observe(v float64) {
+	for i, b := range h.upperBounds {
+		if v <= b {
+			h.buckets[i].add(1)
+		}
+	}
+	h.buckets[len(h.buckets)-1].add(1)
+	h.sum.add(v)
+	h.count.add(1)
+}
+
+func (h *histogram) ObserveAt(t time.Time, v float64) {
+	if !EventTime() {
+		h.Observe(v)
+		return
+	}
+
+	ts := t.Unix()
+	if !observeEvent(ts) {
+		return
+	}
+
+	for i, b := range h.upperBounds {
+		if v <= b {
+			h.buckets[i].addToBucket(ts, 1)
+		}
+	}
+	h.buckets[len(h.buckets)-1].addToBucket(ts, 1)
+	h.sum.addToBucket(ts, v)
+	h.count.addToBucket(ts, 1)
+}
+
+func (h *histogram) Desc() *Desc {
+	return h.desc
+}
+
+// Value returns the number of observations.
+func (h *histogram) Value() float64 {
+	return h.count.Value()
+}
+
+func (h *histogram) Labels() Labels {
+	return h.lset
+}
+
+func (h *histogram) Collect(ch chan<- Metric) {
+	for _, b := range h.buckets {
+		ch <- b
+	}
+	ch <- h.sum
+	ch <- h.count
+}
+
+func (h *histogram) Describe(ch chan<- *Desc) {
+	ch <- h.desc
+}
+
+type HistogramVec struct {
+	*metricVec
+}
+
+// NewHistogramVec returns a histogram partitioned by the given labels. The
+// +Inf bucket is added implicitly.
+func NewHistogramVec(name string, help string, buckets []float64, labelNames []string) *HistogramVec {
+
+	buckets = checkBuckets(buckets)
+
+	desc := NewDesc(name, help, labelNames)
+	bucketDesc := NewDesc(name+"_bucket", help, append(append([]string{}, labelNames...), "le"))
+	sumDesc := NewDesc(name+"_sum", help, labelNames)
+	countDesc := NewDesc(name+"_count", help, labelNames)
+
+	return &HistogramVec{
+		metricVec: newMetricVec(desc, func(lset Labels) Metric {
+			return newHistogram(desc, bucketDesc, sumDesc, countDesc, buckets, lset)
+		}),
+	}
+}
+
+func (v *HistogramVec) WithLabelValues(lvs ...string) Histogram {
+	return v.getOrCreateMetricWithLabelValues(lvs).(Histogram)
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHistogram(t *testing.T) {
+	v := NewHistogramVec("test_size_bytes", "", []float64{10, 100}, []string{"method"})
+	h := v.WithLabelValues("GET")
+	for _, o := range []float64{5, 10, 50, 500} {
+		h.Observe(o)
+	}
+
+	ch := make(chan Metric, 10)
+	v.Collect(ch)
+	close(ch)
+
+	got := map[string]float64{}
+	for m := range ch {
+		key := m.Desc().String()
+		for _, l := range m.Labels() {
+			key += "," + l.Name + "=" + l.Value
+		}
+		got[key] = m.Value()
+	}
+
+	exp := map[string]float64{
+		"test_size_bytes_bucket,method=GET,le=10":   2,
+		"test_size_bytes_bucket,method=GET,le=100":  3,
+		"test_size_bytes_bucket,method=GET,le=+Inf": 4,
+		"test_size_bytes_sum,method=GET":            565,
+		"test_size_bytes_count,method=GET":          4,
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected %v, got %v", exp, got)
+	}
+}
+
+func TestHistogramBucketsOrder(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected unsorted buckets to panic")
+		}
+	}()
+	NewHistogram("test_unsorted", "", []float64{1, 0.5})
+}
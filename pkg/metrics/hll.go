@@ -0,0 +1,282 @@
+package metrics
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// precision of the HyperLogLog sketches, 2^hllPrecision registers give a
+// standard error of about 1.6%
+const hllPrecision = 12
+
+const hllRegisters = 1 << hllPrecision
+
+// hll is a HyperLogLog sketch estimating the number of distinct values
+// added.
+type hll [hllRegisters]uint8
+
+func (h *hll) add(v string) {
+	x := xxhash.Sum64String(v)
+	i := x >> (64 - hllPrecision)
+	// the guard bit bounds the rank if the remaining bits are zero
+	w := x<<hllPrecision | 1<<(hllPrecision-1)
+	rank := uint8(bits.LeadingZeros64(w)) + 1
+	if rank > h[i] {
+		h[i] = rank
+	}
+}
+
+func (h *hll) merge(o *hll) {
+	for i, r := range o {
+		if r > h[i] {
+			h[i] = r
+		}
+	}
+}
+
+func (h *hll) estimate() float64 {
+	m := float64(hllRegisters)
+
+	var sum float64
+	var zeros int
+	for _, r := range h {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	alpha := 0.7213 / (1 + 1.079/m)
+	e := alpha * m * m / sum
+
+	// linear counting is more accurate for small cardinalities
+	if e <= 2.5*m && zeros > 0 {
+		return m * math.Log(m/float64(zeros))
+	}
+	return e
+}
+
+// Cardinality estimates the number of distinct values, like client
+// addresses, over a sliding window. Values are added to sketches of
+// window/slots seconds which are merged for queries over a range.
+type Cardinality interface {
+	Collector
+	Add(string)
+	// AddAt adds the value at the time of the event in event-time mode, it
+	// is equal to Add otherwise. Events of collected buckets or older than
+	// the window are dropped and counted as late.
+	AddAt(time.Time, string)
+	// Estimate returns the number of distinct values of the last range up
+	// to Now.
+	Estimate(last time.Duration) float64
+}
+
+type cardinality struct {
+	mtx sync.Mutex
+
+	desc *Desc
+	lset Labels
+
+	window     time.Duration
+	slotWidth  int64
+	slots      []*hll
+	slotStarts []int64
+}
+
+func newCardinality(desc *Desc, lset Labels, window time.Duration, slots int) *cardinality {
+	width := int64(window/time.Second) / int64(slots)
+	if width < 1 {
+		width = 1
+	}
+	return &cardinality{
+		desc:       desc,
+		lset:       lset,
+		window:     window,
+		slotWidth:  width,
+		slots:      make([]*hll, slots),
+		slotStarts: make([]int64, slots),
+	}
+}
+
+// NewCardinality returns a cardinality estimator without labels.
+func NewCardinality(name string, help string, window time.Duration, slots int) Cardinality {
+	return newCardinality(NewDesc(name, help, nil), nil, window, slots)
+}
+
+func (c *cardinality) Add(v string) {
+	c.AddAt(time.Now(), v)
+}
+
+func (c *cardinality) AddAt(t time.Time, v string) {
+	if !EventTime() {
+		t = time.Now()
+	} else if !observeEvent(t.Unix()) {
+		return
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	start := t.Unix() - t.Unix()%c.slotWidth
+	i := int(start/c.slotWidth) % len(c.slots)
+
+	if c.slots[i] != nil && c.slotStarts[i] > start {
+		// older than the window
+		dropEvent()
+		return
+	}
+	if c.slots[i] == nil || c.slotStarts[i] < start {
+		c.slots[i] = &hll{}
+		c.slotStarts[i] = start
+	}
+	c.slots[i].add(v)
+}
+
+// mergeInto merges the slots of the last range up to now into h and
+// reports whether any slot was merged.
+func (c *cardinality) mergeInto(h *hll, now int64, last time.Duration) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	n := int64(last/time.Second) / c.slotWidth
+	if n < 1 {
+		n = 1
+	}
+	if n > int64(len(c.slots)) {
+		n = int64(len(c.slots))
+	}
+	oldest := now - now%c.slotWidth - (n-1)*c.slotWidth
+
+	merged := false
+	for i, s := range c.slots {
+		if s != nil && c.slotStarts[i] >= oldest && c.slotStarts[i] <= now {
+			h.merge(s)
+			merged = true
+		}
+	}
+	return merged
+}
+
+func (c *cardinality) Estimate(last time.Duration) float64 {
+	var h hll
+	if !c.mergeInto(&h, Now().Unix(), last) {
+		return 0
+	}
+	return h.estimate()
+}
+
+func (c *cardinality) Desc() *Desc {
+	return c.desc
+}
+
+// Value returns the number of distinct values of the whole window.
+func (c *cardinality) Value() float64 {
+	return c.Estimate(c.window)
+}
+
+func (c *cardinality) Labels() Labels {
+	return c.lset
+}
+
+func (c *cardinality) Collect(ch chan<- Metric) {
+	ch <- c
+}
+
+func (c *cardinality) Describe(ch chan<- *Desc) {
+	ch <- c.desc
+}
+
+// CardinalityEstimate is the estimated number of distinct values of a
+// label set.
+type CardinalityEstimate struct {
+	Labels Labels
+	Value  float64
+}
+
+type CardinalityVec struct {
+	*metricVec
+}
+
+// NewCardinalityVec returns a cardinality estimator partitioned by the
+// given labels. Its series hold the estimate of the whole window.
+func NewCardinalityVec(name string, help string, window time.Duration, slots int, labelNames []string) *CardinalityVec {
+
+	desc := NewDesc(name, help, labelNames)
+
+	return &CardinalityVec{
+		metricVec: newMetricVec(desc, func(lset Labels) Metric {
+			return newCardinality(desc, lset, window, slots)
+		}),
+	}
+}
+
+func (v *CardinalityVec) WithLabelValues(lvs ...string) Cardinality {
+	return v.getOrCreateMetricWithLabelValues(lvs).(Cardinality)
+}
+
+func (v *CardinalityVec) children() []*cardinality {
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+
+	var result []*cardinality
+	for _, metrics := range v.metrics {
+		for _, m := range metrics {
+			result = append(result, m.metric.(*cardinality))
+		}
+	}
+	return result
+}
+
+// Estimate returns the number of distinct values of the last range across
+// all label sets. Values seen in several label sets are counted once.
+func (v *CardinalityVec) Estimate(last time.Duration) float64 {
+	var h hll
+	now := Now().Unix()
+	merged := false
+	for _, c := range v.children() {
+		if c.mergeInto(&h, now, last) {
+			merged = true
+		}
+	}
+	if !merged {
+		return 0
+	}
+	return h.estimate()
+}
+
+// EstimateBy returns the number of distinct values of the last range
+// grouped by the given label, values seen in several label sets of a group
+// are counted once.
+func (v *CardinalityVec) EstimateBy(by string, last time.Duration) []CardinalityEstimate {
+	groups := map[string]*hll{}
+	now := Now().Unix()
+
+	for _, c := range v.children() {
+		value := ""
+		for _, l := range c.lset {
+			if l.Name == by {
+				value = l.Value
+			}
+		}
+		h, ok := groups[value]
+		if !ok {
+			h = &hll{}
+		}
+		if c.mergeInto(h, now, last) {
+			groups[value] = h
+		}
+	}
+
+	var result []CardinalityEstimate
+	for value, h := range groups {
+		result = append(result, CardinalityEstimate{
+			Labels: Labels{{Name: by, Value: value}},
+			Value:  h.estimate(),
+		})
+	}
+	return result
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCardinality(t *testing.T) {
+	r := defaultRegistry
+	defer func(e eventTime) {
+		r.eventTime = e
+	}(r.eventTime)
+
+	r.eventTime = eventTime{enabled: true}
+
+	v := NewCardinalityVec("test_distinct_clients", "", 10*time.Minute, 10, []string{"section"})
+
+	// 10000 clients on /api in the first half of the window and the same
+	// clients plus 5000 others on /blog in the second half
+	for i := 0; i < 10000; i++ {
+		v.WithLabelValues("/api").AddAt(time.Unix(1100, 0), fmt.Sprint("10.0.", i))
+		v.WithLabelValues("/blog").AddAt(time.Unix(1400, 0), fmt.Sprint("10.0.", i))
+	}
+	for i := 0; i < 5000; i++ {
+		v.WithLabelValues("/blog").AddAt(time.Unix(1400, 0), fmt.Sprint("10.1.", i))
+	}
+
+	// the buckets up to 1600 are collected
+	r.eventTime.collected = 1600
+
+	within := func(got, exp float64) bool {
+		return math.Abs(got-exp) <= exp*0.05
+	}
+
+	if got := v.Estimate(10 * time.Minute); !within(got, 15000) {
+		t.Fatalf("expected about 15000 distinct clients, got %f", got)
+	}
+	if got := v.WithLabelValues("/api").Estimate(10 * time.Minute); !within(got, 10000) {
+		t.Fatalf("expected about 10000 distinct clients on /api, got %f", got)
+	}
+	// the last 5 minutes cover only /blog
+	if got := v.WithLabelValues("/api").Estimate(5 * time.Minute); got != 0 {
+		t.Fatalf("expected no clients on /api in the last 5 minutes, got %f", got)
+	}
+	for _, e := range v.EstimateBy("section", 5*time.Minute) {
+		if e.Labels[0].Value != "/blog" || !within(e.Value, 15000) {
+			t.Fatalf("unexpected estimate %v", e)
+		}
+	}
+
+	// an event of a collected bucket and one of a slot reused by a newer
+	// event
+	v.WithLabelValues("/late").AddAt(time.Unix(1500, 0), "10.2.0")
+	v.WithLabelValues("/late").AddAt(time.Unix(2300, 0), "10.2.0")
+	v.WithLabelValues("/late").AddAt(time.Unix(1700, 0), "10.2.1")
+	if DroppedLateEvents() != 2 {
+		t.Fatalf("expected 2 dropped events, got %d", DroppedLateEvents())
+	}
+}
+
+func TestCardinalityWallClock(t *testing.T) {
+	r := defaultRegistry
+	defer func(e eventTime) {
+		r.eventTime = e
+	}(r.eventTime)
+
+	r.eventTime = eventTime{}
+
+	// the timestamps of old log lines are ignored without event-time mode
+	c := NewCardinality("test_wall_clock_clients", "", time.Minute, 6)
+	c.AddAt(time.Unix(1000, 0), "10.0.0.1")
+	c.AddAt(time.Unix(1000, 0), "10.0.0.2")
+
+	if got := c.Estimate(time.Minute); math.Abs(got-2) > 0.1 {
+		t.Fatalf("expected 2 distinct values, got %f", got)
+	}
+}
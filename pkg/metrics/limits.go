@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowValue is the value of all labels of the series new label sets are
+// folded into once a series limit is reached.
+const OverflowValue = "__overflow__"
+
+// seriesLimits bound the number of label sets of the metric vectors, 0 is
+// unlimited.
+type seriesLimits struct {
+	mtx sync.RWMutex
+
+	perMetric int
+	global    int
+	byMetric  map[string]int
+
+	// label sets of all metric vectors, overflow series not included
+	series int64
+}
+
+var limits = &seriesLimits{byMetric: map[string]int{}}
+
+// seriesFoldedUpdates counts the updates, not the distinct label sets, folded
+// into the overflow series.
+var seriesFoldedUpdates = newUnlimitedCounterVec(
+	"ltop_series_folded_updates_total",
+	"Counter of updates of new label sets folded into the overflow series as a series limit was reached.",
+	[]string{"metric"},
+)
+
+func init() {
+	Register(seriesFoldedUpdates)
+}
+
+// SetSeriesLimits limits the number of label sets of every metric and of all
+// metrics together, 0 is unlimited.
+func SetSeriesLimits(perMetric, global int) {
+	limits.mtx.Lock()
+	defer limits.mtx.Unlock()
+	limits.perMetric = perMetric
+	limits.global = global
+}
+
+// SetMetricSeriesLimit overrides the per metric series limit of the metric
+// with the given name.
+func SetMetricSeriesLimit(name string, limit int) {
+	limits.mtx.Lock()
+	defer limits.mtx.Unlock()
+	limits.byMetric[name] = limit
+}
+
+// exceeded reports whether another label set of the metric with the given
+// number of label sets exceeds a limit.
+func (l *seriesLimits) exceeded(name string, n int) bool {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+
+	limit := l.perMetric
+	if m, ok := l.byMetric[name]; ok {
+		limit = m
+	}
+	if limit > 0 && n >= limit {
+		return true
+	}
+	return l.global > 0 && atomic.LoadInt64(&l.series) >= int64(l.global)
+}
+
+func overflowLabels(lset Labels) Labels {
+	overflow := make(Labels, len(lset))
+	for i, l := range lset {
+		overflow[i] = Label{Name: l.Name, Value: OverflowValue}
+	}
+	return overflow
+}
+
+// SeriesWarnings returns a warning for every metric which label sets were
+// folded into the overflow series.
+func SeriesWarnings() []string {
+
+	ch := make(chan Metric, capMetricChan)
+	go func() {
+		seriesFoldedUpdates.Collect(ch)
+		close(ch)
+	}()
+
+	var warnings []string
+	for m := range ch {
+		warnings = append(warnings, fmt.Sprintf(
+			"series limit of %s reached, %.0f updates of new label sets were folded into %s",
+			m.Labels().get("metric"), m.Value(), OverflowValue,
+		))
+	}
+	sort.Strings(warnings)
+	return warnings
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSeriesLimits(t *testing.T) {
+	defer func() {
+		SetSeriesLimits(0, 0)
+		delete(limits.byMetric, "test_limited_total")
+	}()
+
+	SetSeriesLimits(0, 0)
+	SetMetricSeriesLimit("test_limited_total", 2)
+
+	v := NewCounterVec("test_limited_total", "", []string{"section", "status"})
+	for _, section := range []string{"/a", "/b", "/c", "/d", "/a"} {
+		v.WithLabelValues(section, "200").Inc()
+	}
+
+	ch := make(chan Metric, 10)
+	v.Collect(ch)
+	close(ch)
+
+	got := map[string]float64{}
+	for m := range ch {
+		got[m.Labels().get("section")] = m.Value()
+	}
+	if len(got) != 3 || got["/a"] != 2 || got["/b"] != 1 || got[OverflowValue] != 2 {
+		t.Fatalf("unexpected series %v", got)
+	}
+
+	var warning string
+	for _, w := range SeriesWarnings() {
+		if strings.Contains(w, "test_limited_total") {
+			warning = w
+		}
+	}
+	if !strings.Contains(warning, "2 updates") {
+		t.Fatalf("unexpected warning %q", warning)
+	}
+}
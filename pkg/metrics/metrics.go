@@ -1,8 +1,12 @@
 package metrics
 
 import (
+	"fmt"
+	"math"
+	"sort"
 	"time"
 	"sync"
+	"sync/atomic"
 	"github.com/cespare/xxhash/v2"
 	//"fmt"
 )
@@ -57,7 +61,8 @@ type Collector interface {
 }
 
 type metricWithLabelValues struct {
-	values []string
+	// labels after relabeling
+	lset   Labels
 	metric Metric
 }
 
@@ -65,7 +70,13 @@ type metricMap struct {
 	mtx       sync.RWMutex // Protects metrics.
 	metrics   map[uint64][]metricWithLabelValues // using slice for handling of hash collision
 	desc      *Desc
-	newMetric func(labelValues ...string) Metric
+	newMetric func(lset Labels) Metric
+	// receives the updates of series dropped by relabeling
+	dropped Metric
+	// number of label sets, the overflow series not included
+	series int
+	// the series limits do not apply
+	unlimited bool
 }
 
 func (m *metricMap) Collect(ch chan<- Metric) {
@@ -74,6 +85,12 @@ func (m *metricMap) Collect(ch chan<- Metric) {
 
 	for _, metrics := range m.metrics {
 		for _, metric := range metrics {
+			// metrics made of several series, like histograms, collect
+			// their series themselves
+			if c, ok := metric.metric.(Collector); ok {
+				c.Collect(ch)
+				continue
+			}
 			ch <- metric.metric
 		}
 	}
@@ -92,23 +109,107 @@ type metricVec struct {
 type Counter interface {
 	Collector
 	Inc()
+	// Add adds the given value to the counter. Negative, NaN and infinite
+	// values are dropped since a counter cannot decrease.
 	Add(float64)
+	// IncAt and AddAt record the increment at the time of the event in
+	// event-time mode, they are equal to Inc and Add otherwise.
+	IncAt(time.Time)
+	AddAt(time.Time, float64)
 }
 
 type counter struct {
-	val  uint64
+	mtx  sync.Mutex
+	val  float64
 	time time.Time
 	lset Labels
 	now func() time.Time
 	desc *Desc
+
+	// event-time mode: increments by start of their bucket
+	pending   map[int64]float64
+	collected int64 // timestamp of the last collected sample
 }
 
 func (c *counter) Inc() {
-	c.val += 1
+	c.Add(1)
 }
 
 func (c *counter) Add(v float64) {
+	if !(v >= 0) || math.IsInf(v, 1) {
+		return
+	}
+	c.add(v)
+}
+
+func (c *counter) add(v float64) {
+	c.mtx.Lock()
+	c.val += v
+	c.mtx.Unlock()
+}
+
+func (c *counter) IncAt(t time.Time) {
+	c.AddAt(t, 1)
+}
+
+func (c *counter) AddAt(t time.Time, v float64) {
+	if !(v >= 0) || math.IsInf(v, 1) {
+		return
+	}
+	if !EventTime() {
+		c.Add(v)
+		return
+	}
+
+	ts := t.Unix()
+	if !observeEvent(ts) {
+		return
+	}
+
+	c.addToBucket(ts, v)
+}
+
+// addToBucket adds v to the event-time bucket of ts.
+func (c *counter) addToBucket(ts int64, v float64) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.pending == nil {
+		c.pending = map[int64]float64{}
+	}
+	c.pending[bucketStart(ts)] += v
+}
+
+// collectUntil returns the cumulative value at the end of every pending
+// bucket ending at or before t. A bucket following a gap is preceded by a
+// sample of the unchanged value at its start, so the increment is not
+// spread over the gap when samples are interpolated.
+func (c *counter) collectUntil(t int64) []Sample {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	width := bucketWidth()
+
+	var buckets []int64
+	for b := range c.pending {
+		if b+width <= t {
+			buckets = append(buckets, b)
+		}
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+
+	var result []Sample
+	for _, b := range buckets {
+		if c.collected < b {
+			result = append(result, Sample{T: b, V: c.val})
+		}
+		c.val += c.pending[b]
+		delete(c.pending, b)
+		c.collected = b + width
+		result = append(result, Sample{T: c.collected, V: c.val})
+	}
 
+	return result
 }
 
 func (c *counter) Desc() *Desc {
@@ -116,7 +217,9 @@ func (c *counter) Desc() *Desc {
 }
 
 func (c *counter) Value() float64 {
-	return float64(c.val)
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.val
 }
 
 func (c *counter) Labels() Labels {
@@ -135,7 +238,7 @@ type CounterVec struct {
 	*metricVec
 }
 
-func newMetricVec(desc *Desc, newMetric func(lvs ...string) Metric) *metricVec {
+func newMetricVec(desc *Desc, newMetric func(lset Labels) Metric) *metricVec {
 	return &metricVec{
 		metricMap: &metricMap{
 			metrics:   map[uint64][]metricWithLabelValues{},
@@ -153,20 +256,32 @@ func NewCounterVec(name string, help string, labelNames []string) *CounterVec {
 	desc := NewDesc(name, help, labelNames)
 
 	return &CounterVec{
-		metricVec: newMetricVec(desc, func(lvs ...string) Metric {
-			var lset Labels
-			for i, lv := range lvs {
-				lset = append(lset, Label{
-					Name: labelNames[i],
-					Value: lv,
-				})
-			}
+		metricVec: newMetricVec(desc, func(lset Labels) Metric {
 			result := &counter{desc: desc, lset: lset, now: time.Now}
 			return result
 		}),
 	}
 }
 
+// newUnlimitedCounterVec returns a counter vector the series limits do not
+// apply to, for the metrics of ltop itself.
+func newUnlimitedCounterVec(name string, help string, labelNames []string) *CounterVec {
+	v := NewCounterVec(name, help, labelNames)
+	v.unlimited = true
+	return v
+}
+
+func makeLabels(labelNames []string, lvs []string) Labels {
+	var lset Labels
+	for i, lv := range lvs {
+		lset = append(lset, Label{
+			Name: labelNames[i],
+			Value: lv,
+		})
+	}
+	return lset
+}
+
 func LabelsEqual(a, b []string) bool {
 	if len(a) != len(b) {
 			return false
@@ -180,38 +295,78 @@ func LabelsEqual(a, b []string) bool {
 }
 
 func (v *CounterVec) WithLabelValues(lvs ...string) Counter {
+	return v.getOrCreateMetricWithLabelValues(lvs).(Counter)
+}
 
-	h, err := v.hashLabelValues(lvs)
-	if err != nil {
-		panic(err)
+// getOrCreateMetricWithLabelValues returns the metric of the label values.
+// The relabel rules of the metric are applied before the labels are hashed,
+// so labels dropped or rewritten do not create series. If the rules drop
+// the series a metric is returned which is never collected.
+func (v *metricVec) getOrCreateMetricWithLabelValues(lvs []string) Metric {
+
+	if len(lvs) != len(v.desc.labels) {
+		panic(fmt.Errorf("%s: expected %d label values, got %d", v.desc, len(v.desc.labels), len(lvs)))
 	}
 
-	if metrics, ok := v.metrics[h]; ok {
-		for _, metric := range metrics {
-			if LabelsEqual(metric.values, lvs) {
-				return metric.metric.(Counter)
-			}
+	lset, keep := relabel(v.desc.name, makeLabels(v.desc.labels, lvs))
+
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	if !keep {
+		if v.dropped == nil {
+			v.dropped = v.newMetric(nil)
 		}
+		return v.dropped
 	}
 
-	inlinedLVs := make([]string, len(lvs))
-	for i := range lvs {
-		inlinedLVs[i] = lvs[i]
+	if metric, ok := v.lookup(lset); ok {
+		return metric
 	}
 
-	metric := v.newMetric(inlinedLVs...)
-	v.metrics[h] = append( v.metrics[h], metricWithLabelValues{values: inlinedLVs, metric: metric})
-	
-	return metric.(Counter)
+	if !v.unlimited && limits.exceeded(v.desc.name, v.series) {
+		seriesFoldedUpdates.WithLabelValues(v.desc.name).Inc()
+		overflow := overflowLabels(lset)
+		if metric, ok := v.lookup(overflow); ok {
+			return metric
+		}
+		return v.create(overflow)
+	}
+
+	if !v.unlimited {
+		v.series++
+		atomic.AddInt64(&limits.series, 1)
+	}
+	return v.create(lset)
 }
 
-func (m *metricVec) hashLabelValues(vals []string) (uint64, error) {
-	var iVals int
+// lookup returns the metric of the label set, v.mtx must be held.
+func (v *metricVec) lookup(lset Labels) (Metric, bool) {
+	if metrics, ok := v.metrics[v.hashLabels(lset)]; ok {
+		for _, metric := range metrics {
+			if labelSetEqual(metric.lset, lset) {
+				return metric.metric, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// create adds a metric of the label set, v.mtx must be held.
+func (v *metricVec) create(lset Labels) Metric {
+	h := v.hashLabels(lset)
+	metric := v.newMetric(lset)
+	v.metrics[h] = append( v.metrics[h], metricWithLabelValues{lset: lset, metric: metric})
+	return metric
+}
+
+func (m *metricVec) hashLabels(lset Labels) uint64 {
 	var h = hashNew()
-	for i := 0; i < len(m.desc.labels); i++ {
-		h = m.hashAdd(h, vals[iVals])
-		iVals++
+	for _, l := range lset {
+		h = m.hashAdd(h, l.Name)
+		h = m.hashAddByte(h, SeparatorByte)
+		h = m.hashAdd(h, l.Value)
 		h = m.hashAddByte(h, SeparatorByte)
 	}
-	return h, nil
-}
\ No newline at end of file
+	return h
+}
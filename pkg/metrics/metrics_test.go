@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCounterAdd(t *testing.T) {
+	c := NewCounterVec("test_bytes_total", "", []string{"method"}).WithLabelValues("GET")
+	c.Add(1.5)
+	c.Inc()
+	c.Add(0)
+
+	if v := c.(Metric).Value(); v != 2.5 {
+		t.Fatalf("unexpected value %f", v)
+	}
+
+	// a counter cannot decrease, invalid increments are dropped
+	c.Add(-1)
+	c.Add(math.NaN())
+	c.Add(math.Inf(1))
+	c.AddAt(time.Now(), -1)
+	c.AddAt(time.Now(), math.Inf(1))
+
+	if v := c.(Metric).Value(); v != 2.5 {
+		t.Fatalf("unexpected value %f after invalid increments", v)
+	}
+}
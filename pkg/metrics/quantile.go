@@ -0,0 +1,250 @@
+package metrics
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/beorn7/perks/quantile"
+)
+
+// defaults of QuantileSummaryOpts
+var (
+	DefObjectives = map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001}
+)
+
+const (
+	DefMaxAge     = 10 * time.Minute
+	DefAgeBuckets = 5
+)
+
+// QuantileSummaryOpts configures a quantile summary.
+type QuantileSummaryOpts struct {
+	Name string
+	Help string
+	// quantiles to estimate mapped to their absolute error, e.g. 0.99: 0.001
+	Objectives map[float64]float64
+	// observations older than MaxAge are not taken into account
+	MaxAge time.Duration
+	// the window of MaxAge slides in AgeBuckets steps
+	AgeBuckets int
+}
+
+// QuantileSummary estimates quantiles of the observations of a sliding
+// window with a streaming algorithm, so no buckets have to be chosen up
+// front. A summary named x is stored as the series x{quantile="<q>"}, and
+// the counters x_sum and x_count.
+type QuantileSummary interface {
+	Collector
+	Observe(float64)
+	// ObserveAt records the observation at the time of the event in
+	// event-time mode, it is equal to Observe otherwise. In event-time mode
+	// the window slides with the event timestamps.
+	ObserveAt(time.Time, float64)
+}
+
+type quantileSummary struct {
+	mtx sync.Mutex
+
+	desc *Desc
+	lset Labels
+
+	objectives []float64
+	targets    map[float64]float64
+
+	// streams[head] holds the observations of the whole window, each stream
+	// is reset once it is MaxAge old, one every MaxAge/AgeBuckets
+	streams     []*quantile.Stream
+	head        int
+	headExpires time.Time
+	bucketWidth time.Duration
+
+	quantiles []*quantileValue
+	sum       *counter
+	count     *counter
+}
+
+// quantileValue is the series of one quantile of a summary.
+type quantileValue struct {
+	summary *quantileSummary
+	q       float64
+	lset    Labels
+}
+
+func (v *quantileValue) Desc() *Desc {
+	return v.summary.desc
+}
+
+func (v *quantileValue) Value() float64 {
+	return v.summary.query(v.q)
+}
+
+func (v *quantileValue) Labels() Labels {
+	return v.lset
+}
+
+func newQuantileSummary(opts QuantileSummaryOpts, desc, sumDesc, countDesc *Desc, lset Labels) *quantileSummary {
+
+	s := &quantileSummary{
+		desc:        desc,
+		lset:        lset,
+		targets:     opts.Objectives,
+		bucketWidth: opts.MaxAge / time.Duration(opts.AgeBuckets),
+		sum:         &counter{desc: sumDesc, lset: lset, now: time.Now},
+		count:       &counter{desc: countDesc, lset: lset, now: time.Now},
+	}
+
+	for q := range opts.Objectives {
+		s.objectives = append(s.objectives, q)
+	}
+	sort.Float64s(s.objectives)
+
+	for i := 0; i < opts.AgeBuckets; i++ {
+		s.streams = append(s.streams, quantile.NewTargeted(s.targets))
+	}
+
+	for _, q := range s.objectives {
+		qLset := append(append(Labels{}, lset...), Label{Name: "quantile", Value: strconv.FormatFloat(q, 'g', -1, 64)})
+		s.quantiles = append(s.quantiles, &quantileValue{summary: s, q: q, lset: qLset})
+	}
+
+	return s
+}
+
+func defaultQuantileSummaryOpts(opts QuantileSummaryOpts) QuantileSummaryOpts {
+	if len(opts.Objectives) == 0 {
+		opts.Objectives = DefObjectives
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = DefMaxAge
+	}
+	if opts.AgeBuckets <= 0 {
+		opts.AgeBuckets = DefAgeBuckets
+	}
+	return opts
+}
+
+// NewQuantileSummary returns a quantile summary without labels.
+func NewQuantileSummary(opts QuantileSummaryOpts) QuantileSummary {
+	opts = defaultQuantileSummaryOpts(opts)
+	return newQuantileSummary(
+		opts,
+		NewDesc(opts.Name, opts.Help, nil),
+		NewDesc(opts.Name+"_sum", opts.Help, nil),
+		NewDesc(opts.Name+"_count", opts.Help, nil),
+		nil,
+	)
+}
+
+func (s *quantileSummary) Observe(v float64) {
+	s.insert(time.Now(), v)
+	s.sum.add(v)
+	s.count.add(1)
+}
+
+func (s *quantileSummary) ObserveAt(t time.Time, v float64) {
+	if !EventTime() {
+		s.Observe(v)
+		return
+	}
+
+	ts := t.Unix()
+	if !observeEvent(ts) {
+		return
+	}
+
+	s.insert(t, v)
+	s.sum.addToBucket(ts, v)
+	s.count.addToBucket(ts, 1)
+}
+
+func (s *quantileSummary) insert(t time.Time, v float64) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.rotate(t)
+	for _, stream := range s.streams {
+		stream.Insert(v)
+	}
+}
+
+// rotate resets the streams which are older than MaxAge at t.
+func (s *quantileSummary) rotate(t time.Time) {
+	if s.headExpires.IsZero() {
+		s.headExpires = t.Add(s.bucketWidth)
+		return
+	}
+	for i := 0; i < len(s.streams) && !t.Before(s.headExpires); i++ {
+		s.streams[s.head].Reset()
+		s.head = (s.head + 1) % len(s.streams)
+		s.headExpires = s.headExpires.Add(s.bucketWidth)
+	}
+	// the window is empty after a gap of MaxAge
+	if !t.Before(s.headExpires) {
+		s.headExpires = t.Add(s.bucketWidth)
+	}
+}
+
+// query returns the estimated quantile q of the window, 0 if there was no
+// observation within MaxAge.
+func (s *quantileSummary) query(q float64) float64 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	// in event-time mode the window slides only with new observations
+	if !EventTime() {
+		s.rotate(time.Now())
+	}
+	return s.streams[s.head].Query(q)
+}
+
+func (s *quantileSummary) Desc() *Desc {
+	return s.desc
+}
+
+// Value returns the number of observations.
+func (s *quantileSummary) Value() float64 {
+	return s.count.Value()
+}
+
+func (s *quantileSummary) Labels() Labels {
+	return s.lset
+}
+
+func (s *quantileSummary) Collect(ch chan<- Metric) {
+	for _, q := range s.quantiles {
+		ch <- q
+	}
+	ch <- s.sum
+	ch <- s.count
+}
+
+func (s *quantileSummary) Describe(ch chan<- *Desc) {
+	ch <- s.desc
+}
+
+type QuantileSummaryVec struct {
+	*metricVec
+}
+
+// NewQuantileSummaryVec returns a quantile summary partitioned by the given
+// labels.
+func NewQuantileSummaryVec(opts QuantileSummaryOpts, labelNames []string) *QuantileSummaryVec {
+
+	opts = defaultQuantileSummaryOpts(opts)
+
+	desc := NewDesc(opts.Name, opts.Help, labelNames)
+	sumDesc := NewDesc(opts.Name+"_sum", opts.Help, labelNames)
+	countDesc := NewDesc(opts.Name+"_count", opts.Help, labelNames)
+
+	return &QuantileSummaryVec{
+		metricVec: newMetricVec(desc, func(lset Labels) Metric {
+			return newQuantileSummary(opts, desc, sumDesc, countDesc, lset)
+		}),
+	}
+}
+
+func (v *QuantileSummaryVec) WithLabelValues(lvs ...string) QuantileSummary {
+	return v.getOrCreateMetricWithLabelValues(lvs).(QuantileSummary)
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestQuantileSummary(t *testing.T) {
+	v := NewQuantileSummaryVec(QuantileSummaryOpts{
+		Name:       "test_duration_seconds",
+		Objectives: map[float64]float64{0.5: 0.01, 0.99: 0.001},
+	}, []string{"section"})
+	s := v.WithLabelValues("/api")
+	for i := 1; i <= 1000; i++ {
+		s.Observe(float64(i))
+	}
+
+	ch := make(chan Metric, 10)
+	v.Collect(ch)
+	close(ch)
+
+	got := map[string]float64{}
+	for m := range ch {
+		key := m.Desc().String()
+		for _, l := range m.Labels() {
+			if l.Name == "quantile" {
+				key += "," + l.Value
+			}
+		}
+		got[key] = m.Value()
+	}
+
+	for key, exp := range map[string]float64{
+		"test_duration_seconds,0.5":   500,
+		"test_duration_seconds,0.99":  990,
+		"test_duration_seconds_sum":   500500,
+		"test_duration_seconds_count": 1000,
+	} {
+		if math.Abs(got[key]-exp) > exp*0.01 {
+			t.Errorf("%s: expected about %f, got %f", key, exp, got[key])
+		}
+	}
+}
+
+func TestQuantileSummaryMaxAge(t *testing.T) {
+	s := newQuantileSummary(
+		defaultQuantileSummaryOpts(QuantileSummaryOpts{MaxAge: time.Minute, AgeBuckets: 2}),
+		NewDesc("test_age", "", nil), NewDesc("test_age_sum", "", nil), NewDesc("test_age_count", "", nil),
+		nil,
+	)
+
+	start := time.Unix(1000, 0)
+	s.insert(start, 100)
+	s.insert(start.Add(40*time.Second), 1)
+
+	// the first observation is older than MaxAge only once both age
+	// buckets rotated
+	s.mtx.Lock()
+	s.rotate(start.Add(70 * time.Second))
+	got := s.streams[s.head].Query(0.99)
+	s.mtx.Unlock()
+	if got != 1 {
+		t.Fatalf("expected the old observation to expire, got %f", got)
+	}
+}
@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 	"fmt"
 	"github.com/golang/glog"
@@ -20,6 +21,7 @@ type Registry struct {
 	collectorsByID        map[uint64]Collector // ID is a hash of the descIDs.
 	seriesSet             map[uint64][]Series
 	collectInterval		  int
+	eventTime             eventTime
 }
 
 func NewRegistry() *Registry {
@@ -34,6 +36,10 @@ func SetCollectInterval(i int) {
 	r.collectInterval = i
 }
 
+func CollectInterval() int {
+	return defaultRegistry.collectInterval
+}
+
 func Register(cs ...Collector) {
 	r := defaultRegistry
 	for _, c := range cs {
@@ -91,6 +97,9 @@ func labelSetEqual(a, b Labels) bool {
 
 
 func (r *Registry) getOrCreateMemSeries(id uint64, lset Labels) *memSeries {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
 	if ss, ok := r.seriesSet[id]; ok {
 		for _, s := range ss {
 			memS := s.(*memSeries)
@@ -116,38 +125,82 @@ func Gather() {
 		glog.Fatal("invalid collect interval")
 	}
 
+	r.Collect()
+
+	for {
+		select {
+
+		case <-time.After(time.Duration(r.collectInterval) * time.Second):
+			r.Collect()
+		}
+	}
+}
+
+// Collect appends the current value of every registered metric to its
+// series. In event-time mode the buckets which are past the allowed lateness
+// are appended instead.
+func (r *Registry) Collect() {
+
+	var until int64
+	if r.eventTime.enabled {
+		until = r.eventTime.collectableUntil()
+	}
+
+	r.CollectUntil(until)
+}
+
+// CollectUntil appends the value of every registered metric to its series.
+// In event-time mode the buckets ending at or before until are appended,
+// otherwise until is ignored and values are appended at the current time.
+func (r *Registry) CollectUntil(until int64) {
+
+	if r.eventTime.enabled {
+		if until <= atomic.LoadInt64(&r.eventTime.collected) {
+			return
+		}
+	}
+
+	r.mtx.RLock()
+	collectors := make([]Collector, 0, len(r.collectorsByID))
 	for _, c := range r.collectorsByID {
+		collectors = append(collectors, c)
+	}
+	r.mtx.RUnlock()
 
-		metricCh := make(chan Metric, capMetricChan)
+	now := time.Now().Unix()
 
-		go func() {
+	for _, c := range collectors {
+
+		metricCh := make(chan Metric, capMetricChan)
 
+		go func(c Collector) {
 			c.Collect(metricCh)
+			close(metricCh)
+		}(c)
 
-			for {
-				select {
-		
-				case <-time.After(time.Duration(r.collectInterval) * time.Second):
-					c.Collect(metricCh)
-				}
+		for x := range metricCh {
+			m := r.getOrCreateMemSeries(x.Desc().id, x.Labels())
 
+			if !r.eventTime.enabled {
+				m.Append(now, x.Value())
+				continue
 			}
-		}()
-		
-		go func() {
-			for {
-				select {
-		
-				case x := <-metricCh:
-					m := r.getOrCreateMemSeries(x.Desc().id, x.Labels())
-					m.Append(time.Now().Unix(), x.Value())
+
+			if em, ok := x.(eventMetric); ok {
+				for _, s := range em.collectUntil(until) {
+					m.Append(s.T, s.V)
 				}
+				continue
 			}
-		}()
 
+			m.Append(until, x.Value())
+		}
 	}
-}
 
+	if r.eventTime.enabled {
+		atomic.StoreInt64(&r.eventTime.collected, until)
+	}
+}
 
 func matchLables(a, selector Labels) bool {
 	return true
@@ -159,6 +212,9 @@ func (r *Registry) Select(name string, selector Labels) []*memSeries {
 
 	id := newHash(name)
 
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
 	if ss, ok := r.seriesSet[id]; ok {
 
 		for _, s := range ss {
@@ -179,55 +235,26 @@ func QueryLast(name string, selector Labels, last int64, evalInterval int64) Mat
 	return r.QueryLast(name, selector, last, evalInterval)
 }
 
-func interpolateSample(t int64, p1, p2 Sample) float64 {
-	return p1.V + (float64(t) * ( (p2.V - p1.V) / float64((p2.T - p1.T)) ) )
-}
-
+// QueryLast returns the values of the matching series for the last seconds
+// up to Now, one point every evalInterval seconds. Values between samples are
+// interpolated, after the last sample of a series its value is carried
+// forward. Points before the first sample of a series are omitted.
 func (r *Registry) QueryLast(name string, selector Labels, last int64, evalInterval int64) Matrix {
 
 	var result Matrix
 
-	t := time.Now().Add(-1 * time.Duration(last) * time.Second)
+	end := Now().Unix()
+	start := end - last
 
 	mss := r.Select(name, selector)
 
 	for _, ms := range mss {
-		
-		it := ms.Iterator()
-		it.Seek(t.Unix())
 
 		ps := NewPointSeries(ms.Labels(), evalInterval)
-
-		startT, _ := it.At()
-
-		var sB Sample
-		var sA Sample
-		var i int64
-
-		for it.Next() {
-
-			iTs := startT + (evalInterval * i)
-
-			t, v := it.At()
-
-			if t == iTs {
-				ps.Points = append(ps.Points, v)
-				i++
-			} else if t < iTs {
-				sB = Sample{T: t, V: v}
-				continue
-			} else if t > iTs {
-				sA = Sample{T: t, V: v}
-				vv := interpolateSample(iTs, sB, sA)
-				ps.Points = append(ps.Points, vv)
-				i++
-			}
-			
-		}
+		ps.Points = ms.pointsBetween(start, end, evalInterval)
 
 		result = append(result, *ps)
-
 	}
 
 	return result
-}
\ No newline at end of file
+}
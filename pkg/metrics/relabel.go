@@ -0,0 +1,212 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// RelabelAction is the action of a relabel rule.
+type RelabelAction string
+
+const (
+	// Replace sets the target label to the replacement if the regex
+	// matches the joined source label values. An empty result removes the
+	// target label.
+	Replace RelabelAction = "replace"
+	// Keep drops the series if the regex does not match.
+	Keep RelabelAction = "keep"
+	// Drop drops the series if the regex matches.
+	Drop RelabelAction = "drop"
+	// HashMod sets the target label to the hash of the joined source label
+	// values modulo Modulus.
+	HashMod RelabelAction = "hashmod"
+	// LabelDrop removes the labels which names match the regex.
+	LabelDrop RelabelAction = "labeldrop"
+	// LabelKeep removes the labels which names do not match the regex.
+	LabelKeep RelabelAction = "labelkeep"
+)
+
+// RelabelConfig is a rule rewriting the labels of a series before it is
+// created, the same as relabel_configs of Prometheus.
+type RelabelConfig struct {
+	SourceLabels []string `json:"source_labels"`
+	// separator of the joined source label values, default ";"
+	Separator string `json:"separator"`
+	// anchored regular expression, default "(.*)"
+	Regex       string `json:"regex"`
+	Modulus     uint64 `json:"modulus"`
+	TargetLabel string `json:"target_label"`
+	// replacement with references to the groups of the regex, default "$1"
+	Replacement *string       `json:"replacement"`
+	Action      RelabelAction `json:"action"`
+}
+
+type relabelRule struct {
+	RelabelConfig
+	re          *regexp.Regexp
+	replacement string
+}
+
+// relabel rules by metric name, a map[string][]*relabelRule
+var relabelRules atomic.Value
+
+func compileRelabelConfig(c RelabelConfig) (*relabelRule, error) {
+
+	r := &relabelRule{RelabelConfig: c, replacement: "$1"}
+
+	if r.Action == "" {
+		r.Action = Replace
+	}
+	if r.Separator == "" {
+		r.Separator = ";"
+	}
+	if r.Replacement != nil {
+		r.replacement = *r.Replacement
+	}
+
+	regex := c.Regex
+	if regex == "" {
+		regex = "(.*)"
+	}
+	re, err := regexp.Compile("^(?:" + regex + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex %q: %s", c.Regex, err)
+	}
+	r.re = re
+
+	switch r.Action {
+	case Replace, HashMod:
+		if r.TargetLabel == "" {
+			return nil, fmt.Errorf("%s requires a target_label", r.Action)
+		}
+		if r.Action == HashMod && r.Modulus == 0 {
+			return nil, fmt.Errorf("hashmod requires a modulus")
+		}
+	case Keep, Drop:
+		if len(r.SourceLabels) == 0 {
+			return nil, fmt.Errorf("%s requires source_labels", r.Action)
+		}
+	case LabelDrop, LabelKeep:
+	default:
+		return nil, fmt.Errorf("unknown relabel action %q", r.Action)
+	}
+
+	return r, nil
+}
+
+// SetRelabelConfigs sets the relabel rules of the metrics by name. The rules
+// apply to the series created afterwards, so they should be set before the
+// log file is read.
+func SetRelabelConfigs(configs map[string][]RelabelConfig) error {
+	rules := map[string][]*relabelRule{}
+	for name, cs := range configs {
+		for i, c := range cs {
+			r, err := compileRelabelConfig(c)
+			if err != nil {
+				return fmt.Errorf("relabel rule %d of %s: %s", i, name, err)
+			}
+			rules[name] = append(rules[name], r)
+		}
+	}
+	relabelRules.Store(rules)
+	return nil
+}
+
+// LoadRelabelConfigs reads relabel rules from a JSON file mapping metric
+// names to lists of rules, e.g.
+//
+//	{"request_total": [{"source_labels": ["status"], "regex": "(\\d)..", "target_label": "status_class", "replacement": "${1}xx"}]}
+func LoadRelabelConfigs(path string) (map[string][]RelabelConfig, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var configs map[string][]RelabelConfig
+	if err := json.Unmarshal(b, &configs); err != nil {
+		return nil, fmt.Errorf("could not parse relabel config %s: %s", path, err)
+	}
+	return configs, nil
+}
+
+func (ls Labels) get(name string) string {
+	for _, l := range ls {
+		if l.Name == name {
+			return l.Value
+		}
+	}
+	return ""
+}
+
+// set sets or, if value is empty, removes the label.
+func (ls Labels) set(name, value string) Labels {
+	for i, l := range ls {
+		if l.Name == name {
+			if value == "" {
+				return append(ls[:i:i], ls[i+1:]...)
+			}
+			ls[i].Value = value
+			return ls
+		}
+	}
+	if value == "" {
+		return ls
+	}
+	return append(ls, Label{Name: name, Value: value})
+}
+
+// relabel applies the relabel rules of the metric to lset. It reports
+// false if the series is dropped.
+func relabel(name string, lset Labels) (Labels, bool) {
+
+	rules, _ := relabelRules.Load().(map[string][]*relabelRule)
+	if len(rules[name]) == 0 {
+		return lset, true
+	}
+
+	for _, r := range rules[name] {
+
+		values := make([]string, len(r.SourceLabels))
+		for i, n := range r.SourceLabels {
+			values[i] = lset.get(n)
+		}
+		value := strings.Join(values, r.Separator)
+
+		switch r.Action {
+		case Keep:
+			if !r.re.MatchString(value) {
+				return nil, false
+			}
+		case Drop:
+			if r.re.MatchString(value) {
+				return nil, false
+			}
+		case Replace:
+			m := r.re.FindStringSubmatchIndex(value)
+			if m == nil {
+				continue
+			}
+			result := r.re.ExpandString(nil, r.replacement, value, m)
+			lset = lset.set(r.TargetLabel, string(result))
+		case HashMod:
+			mod := xxhash.Sum64String(value) % r.Modulus
+			lset = lset.set(r.TargetLabel, strconv.FormatUint(mod, 10))
+		case LabelDrop, LabelKeep:
+			kept := lset[:0:0]
+			for _, l := range lset {
+				if r.re.MatchString(l.Name) == (r.Action == LabelKeep) {
+					kept = append(kept, l)
+				}
+			}
+			lset = kept
+		}
+	}
+
+	return lset, true
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+func TestRelabel(t *testing.T) {
+	defer relabelRules.Store(map[string][]*relabelRule{})
+
+	empty := ""
+	err := SetRelabelConfigs(map[string][]RelabelConfig{
+		"test_requests_total": {
+			{SourceLabels: []string{"section"}, Regex: "/health.*", Action: Drop},
+			{SourceLabels: []string{"status"}, Regex: `(\d)..`, TargetLabel: "status_class", Replacement: strPtr("${1}xx")},
+			{SourceLabels: []string{"client"}, TargetLabel: "shard", Modulus: 4, Action: HashMod},
+			{Regex: "status|client", Action: LabelDrop},
+			{SourceLabels: []string{"section"}, Regex: "/tmp", TargetLabel: "section", Replacement: &empty},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := NewCounterVec("test_requests_total", "", []string{"section", "status", "client"})
+	v.WithLabelValues("/api", "500", "10.0.0.1").Inc()
+	v.WithLabelValues("/api", "503", "10.0.0.1").Inc()
+	v.WithLabelValues("/health", "200", "10.0.0.1").Inc()
+	v.WithLabelValues("/tmp", "200", "10.0.0.1").Inc()
+
+	ch := make(chan Metric, 10)
+	v.Collect(ch)
+	close(ch)
+
+	got := map[string]float64{}
+	for m := range ch {
+		got[m.Labels().String()] = m.Value()
+	}
+
+	shard := strconv.FormatUint(xxhash.Sum64String("10.0.0.1")%4, 10)
+	exp := map[string]float64{
+		Labels{{"section", "/api"}, {"status_class", "5xx"}, {"shard", shard}}.String(): 2,
+		Labels{{"status_class", "2xx"}, {"shard", shard}}.String():                      1,
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected %v, got %v", exp, got)
+	}
+}
+
+func TestRelabelConfigErrors(t *testing.T) {
+	for _, c := range []RelabelConfig{
+		{Action: "unknown"},
+		{Regex: "("},
+		{Action: HashMod, TargetLabel: "shard"},
+		{Action: Keep},
+		{Action: Replace},
+	} {
+		if _, err := compileRelabelConfig(c); err == nil {
+			t.Errorf("expected an error for %+v", c)
+		}
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -4,7 +4,6 @@ import (
 	"sync"
 	"github.com/almariah/ltop/chunkenc"
 	"math"
-	"time"
 	//"fmt"
 ) 
 
@@ -74,7 +73,7 @@ func NewMemSeries(id uint64, lset Labels, chunkRange int64) *memSeries {
 		ref: id,
 		lset: lset,
 		chunkRange: chunkRange,
-		mint: time.Now().Unix(),
+		mint: math.MinInt64,
 	}
 }
 
@@ -95,7 +94,7 @@ func computeChunkEndTime(start, cur, max int64) int64 {
 func (s *memSeries) truncatable() int64 {
 	var before int64
 	if s.maxt-s.mint > s.chunkRange/2*3 {
-		before = (s.maxt-s.mint)/3*2
+		before = s.maxt - s.chunkRange
 	}
 	return before
 }
@@ -148,6 +147,9 @@ func (s *memSeries) cut(mint int64) *memChunk {
 }
 
 func (s *memSeries) Append(t int64, v float64) (success, chunkCreated bool) {
+	s.Lock()
+	defer s.Unlock()
+
 	// Based on Gorilla white papers this offers near-optimal compression ratio
 	// so anything bigger that this has diminishing returns and increases
 	// the time range within which we have to decompress all samples.
@@ -158,6 +160,7 @@ func (s *memSeries) Append(t int64, v float64) (success, chunkCreated bool) {
 	if c == nil {
 		c = s.cut(t)
 		chunkCreated = true
+		s.mint = t
 	}
 	numSamples := c.chunk.NumSamples()
 
@@ -188,24 +191,79 @@ func (s *memSeries) Append(t int64, v float64) (success, chunkCreated bool) {
 
 	if before := s.truncatable(); before > 0 {
 		s.truncateChunksBefore(before)
+		s.mint = s.chunks[0].minTime
 	}
 
 	return true, chunkCreated
 }
 
-func (s memSeries) Labels() Labels {
+func (s *memSeries) Labels() Labels {
 	return s.lset
 }
 
-func (s memSeries) NumSamples() int {
+func (s *memSeries) NumSamples() int {
+	s.Lock()
+	defer s.Unlock()
 
 	var n int
 
 	for _, chunk := range s.chunks {
-		n += chunk.chunk.NumSamples()  
+		n += chunk.chunk.NumSamples()
+	}
+
+	return n
+}
+
+// pointsBetween returns the values of the series from start to end, one
+// value every step seconds. Values between samples are interpolated linearly,
+// values after the last sample are the value of the last sample and values
+// before the first sample are omitted.
+func (s *memSeries) pointsBetween(start, end, step int64) []float64 {
+	s.Lock()
+	defer s.Unlock()
+
+	var (
+		points []float64
+		prev   sample
+		seen   bool
+		ts     = start
+	)
+
+	// the last sample before start is needed for interpolation
+	first := 0
+	for i, c := range s.chunks {
+		if c.maxTime < start {
+			first = i
+		}
+	}
+
+	for _, c := range s.chunks[first:] {
+		it := c.chunk.Iterator(nil)
+		for it.Next() {
+			t, v := it.At()
+
+			for ; ts <= end && ts <= t; ts += step {
+				if ts == t {
+					points = append(points, v)
+				} else if seen {
+					points = append(points, prev.v+(v-prev.v)*float64(ts-prev.t)/float64(t-prev.t))
+				}
+			}
+
+			prev = sample{t: t, v: v}
+			seen = true
+
+			if ts > end {
+				return points
+			}
+		}
+	}
+
+	for ; seen && ts <= end; ts += step {
+		points = append(points, prev.v)
 	}
 
-	return n + s.headChunk.chunk.NumSamples()  
+	return points
 }
 
 func (s *memSeries) Iterator() Iterator {
@@ -0,0 +1,220 @@
+package metrics
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TopKEntry is an estimated heavy hitter. Count is an upper bound of the
+// occurrences of Key, Count-Error a lower bound.
+type TopKEntry struct {
+	Key   string
+	Count float64
+	Error float64
+}
+
+// TopK tracks the most frequent keys of a high-cardinality field, like the
+// remote host of a request, in bounded memory. It uses the space-saving
+// algorithm: at most capacity keys are tracked and a new key replaces the
+// least frequent one, inheriting its count as error. Keys are counted in
+// slots of window/slots seconds, so the top keys are those of the last
+// window.
+type TopK struct {
+	mtx sync.Mutex
+
+	capacity   int
+	slotWidth  int64
+	slots      []*spaceSaving
+	slotStarts []int64
+}
+
+// NewTopK returns a tracker of at most capacity keys per slot.
+func NewTopK(capacity int, window time.Duration, slots int) *TopK {
+	width := int64(window/time.Second) / int64(slots)
+	if width < 1 {
+		width = 1
+	}
+	t := &TopK{
+		capacity:   capacity,
+		slotWidth:  width,
+		slots:      make([]*spaceSaving, slots),
+		slotStarts: make([]int64, slots),
+	}
+	for i := range t.slots {
+		t.slots[i] = newSpaceSaving(capacity)
+	}
+	return t
+}
+
+// Add counts one occurrence of key now.
+func (t *TopK) Add(key string) {
+	t.AddAt(time.Now(), key, 1)
+}
+
+// AddAt counts v occurrences of key at the time of the event in event-time
+// mode, it is equal to Add otherwise. Events of collected buckets or older
+// than the window are dropped and counted as late.
+func (t *TopK) AddAt(ts time.Time, key string, v float64) {
+	if !EventTime() {
+		ts = time.Now()
+	} else if !observeEvent(ts.Unix()) {
+		return
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	start := ts.Unix() - ts.Unix()%t.slotWidth
+	i := int(start/t.slotWidth) % len(t.slots)
+
+	if t.slotStarts[i] > start {
+		// older than the window
+		dropEvent()
+		return
+	}
+	if t.slotStarts[i] < start {
+		t.slots[i].reset()
+		t.slotStarts[i] = start
+	}
+	t.slots[i].add(key, v)
+}
+
+// Top returns the n most frequent keys of the window ending at Now, by
+// descending count.
+func (t *TopK) Top(n int) []TopKEntry {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	now := Now().Unix()
+	oldest := now - now%t.slotWidth - int64(len(t.slots)-1)*t.slotWidth
+
+	var live []*spaceSaving
+	for i, s := range t.slots {
+		if t.slotStarts[i] >= oldest && t.slotStarts[i] <= now {
+			live = append(live, s)
+		}
+	}
+
+	// a key missing in a full slot may have occurred up to the minimum
+	// count of that slot
+	merged := map[string]*TopKEntry{}
+	for _, s := range live {
+		for _, c := range s.counters {
+			e, ok := merged[c.key]
+			if !ok {
+				e = &TopKEntry{Key: c.key}
+				merged[c.key] = e
+			}
+			e.Count += c.count
+			e.Error += c.err
+		}
+	}
+	for _, s := range live {
+		min := s.min()
+		if min == 0 {
+			continue
+		}
+		for key, e := range merged {
+			if _, ok := s.index[key]; !ok {
+				e.Count += min
+				e.Error += min
+			}
+		}
+	}
+
+	result := make([]TopKEntry, 0, len(merged))
+	for _, e := range merged {
+		result = append(result, *e)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Key < result[j].Key
+	})
+	if len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+type ssCounter struct {
+	key   string
+	count float64
+	err   float64
+	index int
+}
+
+// spaceSaving is a min-heap of the tracked counters by count.
+type spaceSaving struct {
+	capacity int
+	counters []*ssCounter
+	index    map[string]*ssCounter
+}
+
+func newSpaceSaving(capacity int) *spaceSaving {
+	return &spaceSaving{
+		capacity: capacity,
+		index:    map[string]*ssCounter{},
+	}
+}
+
+func (s *spaceSaving) Len() int           { return len(s.counters) }
+func (s *spaceSaving) Less(i, j int) bool { return s.counters[i].count < s.counters[j].count }
+func (s *spaceSaving) Swap(i, j int) {
+	s.counters[i], s.counters[j] = s.counters[j], s.counters[i]
+	s.counters[i].index = i
+	s.counters[j].index = j
+}
+
+func (s *spaceSaving) Push(x interface{}) {
+	c := x.(*ssCounter)
+	c.index = len(s.counters)
+	s.counters = append(s.counters, c)
+}
+
+func (s *spaceSaving) Pop() interface{} {
+	c := s.counters[len(s.counters)-1]
+	s.counters = s.counters[:len(s.counters)-1]
+	return c
+}
+
+func (s *spaceSaving) add(key string, v float64) {
+	if c, ok := s.index[key]; ok {
+		c.count += v
+		heap.Fix(s, c.index)
+		return
+	}
+
+	if len(s.counters) < s.capacity {
+		c := &ssCounter{key: key, count: v}
+		heap.Push(s, c)
+		s.index[key] = c
+		return
+	}
+
+	// replace the least frequent key
+	c := s.counters[0]
+	delete(s.index, c.key)
+	c.key = key
+	c.err = c.count
+	c.count += v
+	s.index[key] = c
+	heap.Fix(s, 0)
+}
+
+// min returns the count a key not tracked may have, 0 while the capacity
+// is not reached.
+func (s *spaceSaving) min() float64 {
+	if len(s.counters) < s.capacity {
+		return 0
+	}
+	return s.counters[0].count
+}
+
+func (s *spaceSaving) reset() {
+	s.counters = s.counters[:0]
+	s.index = map[string]*ssCounter{}
+}
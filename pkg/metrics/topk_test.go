@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTopK(t *testing.T) {
+	r := defaultRegistry
+	defer func(e eventTime) {
+		r.eventTime = e
+	}(r.eventTime)
+
+	r.eventTime = eventTime{enabled: true}
+
+	tk := NewTopK(3, time.Minute, 6)
+
+	// an expired slot
+	tk.AddAt(time.Unix(990, 0), "old", 100)
+
+	for i := 0; i < 10; i++ {
+		tk.AddAt(time.Unix(1010, 0), "a", 1)
+	}
+	for i := 0; i < 5; i++ {
+		tk.AddAt(time.Unix(1020, 0), "b", 1)
+	}
+	// rare keys replace each other in the last counter
+	for i := 0; i < 4; i++ {
+		tk.AddAt(time.Unix(1030, 0), fmt.Sprintf("rare%d", i), 1)
+	}
+
+	// the buckets up to 1060 are collected
+	r.eventTime.collected = 1060
+
+	// a and b may have occurred once in the full slot of the rare keys
+	top := tk.Top(2)
+	exp := []TopKEntry{{Key: "a", Count: 11, Error: 1}, {Key: "b", Count: 6, Error: 1}}
+	if len(top) != 2 || top[0] != exp[0] || top[1] != exp[1] {
+		t.Fatalf("expected %v, got %v", exp, top)
+	}
+
+	all := tk.Top(10)
+	if len(all) != 5 || all[2] != (TopKEntry{Key: "rare3", Count: 2, Error: 1}) {
+		t.Fatalf("unexpected entries %v", all)
+	}
+
+	// an event of a collected bucket and one of a slot reused by a newer
+	// event
+	tk.AddAt(time.Unix(1030, 0), "late", 1)
+	tk.AddAt(time.Unix(1130, 0), "c", 1)
+	tk.AddAt(time.Unix(1070, 0), "stale", 1)
+	if DroppedLateEvents() != 2 {
+		t.Fatalf("expected 2 dropped events, got %d", DroppedLateEvents())
+	}
+}
+
+func TestTopKWallClock(t *testing.T) {
+	r := defaultRegistry
+	defer func(e eventTime) {
+		r.eventTime = e
+	}(r.eventTime)
+
+	r.eventTime = eventTime{}
+
+	// the timestamps of old log lines are ignored without event-time mode
+	tk := NewTopK(3, time.Minute, 6)
+	tk.AddAt(time.Unix(1000, 0), "a", 2)
+
+	if top := tk.Top(1); len(top) != 1 || top[0] != (TopKEntry{Key: "a", Count: 2}) {
+		t.Fatalf("unexpected entries %v", top)
+	}
+}
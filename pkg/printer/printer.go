@@ -9,8 +9,9 @@ import (
 )
 
 const (
-	noticeColor = "\033[1;36m%s\033[0m"
-	errorColor  = "\033[1;31m%s\033[0m"
+	noticeColor  = "\033[1;36m%s\033[0m"
+	errorColor   = "\033[1;31m%s\033[0m"
+	warningColor = "\033[1;33m%s\033[0m"
 )
 
 type Table struct {
@@ -27,6 +28,8 @@ type Graph struct {
 type Summary struct {
 	Tables []Table
 	Graphs []Graph
+	// problems of the monitoring itself, like series limits reached
+	Warnings []string
 }
 
 type Printer struct {
@@ -41,6 +44,12 @@ func NewPrinter(out io.Writer) *Printer {
 
 func (p *Printer) Render(s Summary) {
 
+	for _, w := range s.Warnings {
+		p.Out.Write([]byte("\n"))
+		p.Out.Write([]byte(fmt.Sprintf(warningColor, "WARNING: "+w)))
+		p.Out.Write([]byte("\n"))
+	}
+
 	for _, t := range s.Tables {
 		p.Out.Write([]byte("\n"))
 		p.Out.Write([]byte(t.Title))
@@ -0,0 +1,145 @@
+package replay
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/almariah/ltop/pkg/filter"
+	"github.com/almariah/ltop/pkg/log"
+	"github.com/almariah/ltop/pkg/metrics"
+	"github.com/almariah/ltop/pkg/printer"
+)
+
+// Config configures a replay.
+type Config struct {
+	// joins lines into entries, nil if every line is an entry
+	Multiline *log.MultilineConfig
+	// evaluation interval of the final summary in seconds
+	EvalInterval int64
+	// interval in seconds the monitors are evaluated at while the simulated
+	// clock jumps over gaps, default the collect interval
+	Step int64
+}
+
+// Report is the result of a replay.
+type Report struct {
+	// alerts which changed their status, ordered by time
+	Alerts  []metrics.Monitor
+	Summary printer.Summary
+
+	Entries int
+	Errors  int
+	// events dropped as they arrived later than the allowed lateness
+	Dropped uint64
+
+	// first and last collected time of the simulated clock
+	Start, End time.Time
+}
+
+// Run reads the log entries from r to the end as fast as possible and hands
+// them to the filter. The metrics are collected and the monitors evaluated
+// on a simulated clock driven by the event timestamps, so the event-time
+// mode has to be enabled and the metrics and monitors of the filter have to
+// be registered before.
+func Run(f filter.Filter, r io.Reader, config Config) (*Report, error) {
+
+	var joiner *log.MultilineJoiner
+	if config.Multiline != nil {
+		j, err := log.NewMultilineJoiner(*config.Multiline)
+		if err != nil {
+			return nil, err
+		}
+		joiner = j
+	}
+
+	if config.Step <= 0 {
+		config.Step = int64(metrics.CollectInterval())
+	}
+
+	report := &Report{}
+
+	handle := func(e log.Entry) {
+		last := metrics.Watermark()
+		report.Entries++
+		if err := f.HandleEntry(e.Time, e.Text); err != nil {
+			report.Errors++
+		}
+		advance(report, metrics.Collectable(), last, config.Step)
+	}
+
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			line = strings.TrimRight(line, "\r\n")
+			// entries without their own timestamp are read at the current
+			// time of the simulated clock
+			t := metrics.Collected()
+			if joiner == nil {
+				handle(log.Entry{Time: t, Text: line})
+			} else {
+				for _, e := range joiner.Add(t, line) {
+					handle(e)
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if joiner != nil {
+		if e, ok := joiner.Flush(); ok {
+			handle(e)
+		}
+	}
+
+	// collect the buckets still waiting for late events
+	end := metrics.Watermark()
+	if !end.IsZero() {
+		advance(report, end.Add(time.Duration(config.Step)*time.Second), end, config.Step)
+	}
+
+	report.End = metrics.Collected()
+	report.Dropped = metrics.DroppedLateEvents()
+	report.Summary = f.Summary(config.EvalInterval)
+	report.Summary.Warnings = append(report.Summary.Warnings, metrics.SeriesWarnings()...)
+
+	return report, nil
+}
+
+// advance moves the simulated clock forward to until, evaluating the
+// monitors at most step seconds apart. No events are pending after the
+// bucket of the last event seen before the current one, so a gap in the log
+// after it is skipped and the clock jumps straight to until.
+func advance(report *Report, until time.Time, last time.Time, step int64) {
+
+	now := metrics.Collected()
+	if !until.After(now) || until.Unix() <= 0 {
+		return
+	}
+
+	if now.Unix() <= 0 {
+		// the first collection starts the simulated clock
+		metrics.CollectUntil(until)
+		report.Start = metrics.Collected()
+		metrics.Evaluate(report.Start)
+		return
+	}
+
+	idle := last.Add(time.Duration(metrics.CollectInterval()) * time.Second)
+
+	for now.Before(until) {
+		now = now.Add(time.Duration(step) * time.Second)
+		if now.After(until) || now.After(idle) {
+			now = until
+		}
+		metrics.CollectUntil(now)
+		report.Alerts = append(report.Alerts, metrics.Evaluate(now)...)
+	}
+}
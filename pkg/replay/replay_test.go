@@ -0,0 +1,58 @@
+package replay
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	httpfilter "github.com/almariah/ltop/pkg/filter/http"
+	"github.com/almariah/ltop/pkg/metrics"
+)
+
+func TestRunAccessLog(t *testing.T) {
+
+	file, err := os.Open("../../access.log")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer file.Close()
+
+	metrics.SetCollectInterval(5)
+	metrics.SetEventTime(30 * time.Second)
+	httpfilter.SetAlertThreshold(2)
+	httpfilter.SetAlertEvaluateInterval(120)
+
+	f := httpfilter.NewHTTPAccessLogFilter()
+	f.RegisterMetrics()
+	f.RegisterMonitors()
+
+	report, err := Run(f, file, Config{EvalInterval: 10, Step: 120})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Entries == 0 || report.Errors == report.Entries {
+		t.Fatalf("expected parsed entries, got %d entries and %d errors", report.Entries, report.Errors)
+	}
+
+	start := time.Date(2015, 12, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2016, 2, 1, 0, 0, 0, 0, time.UTC)
+	if report.Start.Before(start) || !report.End.After(report.Start) || report.End.After(end) {
+		t.Fatalf("unexpected replayed range %s - %s", report.Start, report.End)
+	}
+
+	if len(report.Alerts) == 0 {
+		t.Fatal("expected alerts")
+	}
+	for i, a := range report.Alerts {
+		if i > 0 && a.StatusTime().Before(report.Alerts[i-1].StatusTime()) {
+			t.Fatalf("alert %d at %s is before the previous one", i, a.StatusTime())
+		}
+		if a.StatusTime().Before(report.Start) || a.StatusTime().After(report.End) {
+			t.Fatalf("alert at %s outside of the replayed range", a.StatusTime())
+		}
+	}
+	if report.Alerts[0].Status() != "TRIGGERED" {
+		t.Fatalf("expected the first alert to trigger, got %s", report.Alerts[0].Status())
+	}
+}